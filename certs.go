@@ -0,0 +1,366 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type certsConfig struct {
+	// Command line options
+	help          bool
+	dir           string
+	hosts         string
+	clientHosts   string
+	keyType       string
+	clientKeyType string
+	validity      time.Duration
+	force         bool
+}
+
+func certsCmd() command {
+	fset := flag.NewFlagSet("netperf certs", flag.ExitOnError)
+	config := certsConfig{}
+	fset.BoolVar(&config.help, "help", false, "")
+	fset.StringVar(&config.dir, "dir", ".", "")
+	fset.StringVar(&config.hosts, "hosts", "", "")
+	fset.StringVar(&config.clientHosts, "client-hosts", "", "")
+	fset.StringVar(&config.keyType, "key-type", defaultKeyType, "")
+	fset.StringVar(&config.clientKeyType, "client-key-type", "", "")
+	fset.DurationVar(&config.validity, "validity", defaultCertValidity, "")
+	fset.BoolVar(&config.force, "force", false, "")
+	run := func(args []string) error {
+		fset.Usage = func() {
+			certsUsage(args[0], os.Stderr)
+		}
+		fset.Parse(args[1:])
+		posArgs := fset.Args()
+		if len(posArgs) != 0 {
+			return fmt.Errorf("unexpected argument %q", posArgs[0])
+		}
+		return certsRun(args[0], config)
+	}
+	return command{fset: fset, run: run}
+}
+
+func certsRun(cmdName string, config certsConfig) error {
+	if config.help {
+		certsUsage(cmdName, os.Stderr)
+		return nil
+	}
+	errlog = setErrlog(cmdName)
+	if config.validity <= 0 {
+		return fmt.Errorf("invalid validity value %s", config.validity)
+	}
+	if config.clientKeyType == "" {
+		config.clientKeyType = config.keyType
+	}
+	for _, keyType := range []string{config.keyType, config.clientKeyType} {
+		if !containsString(keyTypes, keyType) {
+			return fmt.Errorf("unknown key type %q (valid types: %s)", keyType, strings.Join(keyTypes, ", "))
+		}
+	}
+	hosts := defaultCertHosts()
+	if config.hosts != "" {
+		hosts = splitList(config.hosts)
+	}
+	clientHosts := splitList(config.clientHosts)
+
+	// Refuse to overwrite any file before writing the first one
+	files := []string{defaultReceiverCA, defaultReceiverCert, defaultReceiverKey, defaultClientCert, defaultClientKey}
+	paths := make(map[string]string, len(files))
+	for _, f := range files {
+		paths[f] = filepath.Join(config.dir, f)
+		if fileExists(paths[f]) && !config.force {
+			return fmt.Errorf("file %q already exists (use -force to overwrite it)", paths[f])
+		}
+	}
+
+	// The key of the CA is only used for signing the certificates below
+	caKey, err := generateKey(config.keyType)
+	if err != nil {
+		return err
+	}
+	ca, caDER, err := issueCert(certTemplate("netperf CA", nil, config.validity, 0), caKey, nil, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create CA certificate: %s", err)
+	}
+	pairs := []struct {
+		name     string
+		hosts    []string
+		keyType  string
+		usage    x509.ExtKeyUsage
+		certFile string
+		keyFile  string
+	}{
+		{"netperf receiver", hosts, config.keyType, x509.ExtKeyUsageServerAuth, defaultReceiverCert, defaultReceiverKey},
+		{"netperf sender", clientHosts, config.clientKeyType, x509.ExtKeyUsageClientAuth, defaultClientCert, defaultClientKey},
+	}
+	if err := writePEM(paths[defaultReceiverCA], "CERTIFICATE", caDER, 0644); err != nil {
+		return err
+	}
+	for _, p := range pairs {
+		key, err := generateKey(p.keyType)
+		if err != nil {
+			return err
+		}
+		_, der, err := issueCert(certTemplate(p.name, p.hosts, config.validity, p.usage), key, ca, caKey)
+		if err != nil {
+			return fmt.Errorf("cannot create %s certificate: %s", p.name, err)
+		}
+		keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return err
+		}
+		if err := writePEM(paths[p.certFile], "CERTIFICATE", der, 0644); err != nil {
+			return err
+		}
+		if err := writePEM(paths[p.keyFile], "PRIVATE KEY", keyDER, 0600); err != nil {
+			return err
+		}
+	}
+	errlog.Printf("CA certificate written to %s\n", paths[defaultReceiverCA])
+	errlog.Printf("receiver certificate for %s written to %s and %s\n", strings.Join(hosts, ", "), paths[defaultReceiverCert], paths[defaultReceiverKey])
+	errlog.Printf("sender certificate written to %s and %s\n", paths[defaultClientCert], paths[defaultClientKey])
+	return nil
+}
+
+// keyTypes are the types of the keys which can be generated
+var keyTypes = []string{"ecdsa-p256", "ecdsa-p384", "ecdsa-p521", "ed25519", "rsa-2048", "rsa-3072", "rsa-4096"}
+
+// generateKey returns a new private key of the given type, one of keyTypes
+func generateKey(keyType string) (crypto.Signer, error) {
+	switch keyType {
+	case "ecdsa-p256":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ecdsa-p384":
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ecdsa-p521":
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "ed25519":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	case "rsa-2048":
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case "rsa-3072":
+		return rsa.GenerateKey(rand.Reader, 3072)
+	case "rsa-4096":
+		return rsa.GenerateKey(rand.Reader, 4096)
+	}
+	return nil, fmt.Errorf("unknown key type %q", keyType)
+}
+
+// certTemplate returns the template of a certificate with the given common
+// name, valid for hosts, which are host names or IP addresses, from now on
+// for the given duration. The certificate is a CA certificate if usage is
+// zero.
+func certTemplate(name string, hosts []string, validity time.Duration, usage x509.ExtKeyUsage) *x509.Certificate {
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             now.Add(-time.Hour), // tolerate clock skew
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	if usage == 0 {
+		tmpl.IsCA = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	} else {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	return tmpl
+}
+
+// issueCert returns the certificate of key built from tmpl, in parsed and
+// DER forms, signed by ca with caKey. It is self-signed if ca is nil.
+func issueCert(tmpl *x509.Certificate, key crypto.Signer, ca *x509.Certificate, caKey crypto.Signer) (*x509.Certificate, []byte, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl.SerialNumber = serial
+	if _, ok := key.(*rsa.PrivateKey); ok && !tmpl.IsCA {
+		// Required by RSA key exchanges, before TLS 1.3
+		tmpl.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	if ca == nil {
+		ca, caKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, key.Public(), caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, der, nil
+}
+
+// selfSignedCert returns a self-signed certificate for the receiver, with
+// a new private key of the given type which is only kept in memory
+func selfSignedCert(keyType string) (tls.Certificate, error) {
+	key, err := generateKey(keyType)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := certTemplate("netperf receiver", defaultCertHosts(), defaultCertValidity, x509.ExtKeyUsageServerAuth)
+	cert, der, err := issueCert(tmpl, key, nil, nil)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, nil
+}
+
+// fingerprint returns the SHA-256 fingerprint of the DER-encoded certificate
+// der, in the form printed by openssl: uppercase hexadecimal bytes separated
+// by colons
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	s := strings.ToUpper(hex.EncodeToString(sum[:]))
+	var b strings.Builder
+	for i := 0; i < len(s); i += 2 {
+		if i > 0 {
+			b.WriteByte(':')
+		}
+		b.WriteString(s[i : i+2])
+	}
+	return b.String()
+}
+
+// parseFingerprint parses a SHA-256 fingerprint in hexadecimal, in any
+// case, with or without colons between the bytes
+func parseFingerprint(s string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, ":", ""))
+	if err != nil || len(b) != sha256.Size {
+		return nil, fmt.Errorf("not a SHA-256 fingerprint")
+	}
+	return b, nil
+}
+
+// writePEM writes der as a single PEM block of the given type to the file
+// at path, created with mode perm if it does not exist
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(f, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// defaultCertHosts returns the names the receiver certificate is valid for
+// by default: the loopback addresses and the name of this host
+func defaultCertHosts() []string {
+	hosts := []string{"localhost"}
+	if name, err := os.Hostname(); err == nil && name != "" && name != "localhost" {
+		hosts = append(hosts, name)
+	}
+	return append(hosts, "127.0.0.1", "::1")
+}
+
+// splitList returns the non-empty elements of the comma-separated list s
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+func certsUsage(cmd string, f *os.File) {
+	const template = `
+USAGE:
+{{.Tab1}}{{.AppName}} {{.SubCmd}} [-dir <directory>] [-hosts <list>] [-client-hosts <list>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-key-type <type>] [-client-key-type <type>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-validity <duration>] [-force]
+{{.Tab1}}{{.AppName}} {{.SubCmd}} -help
+
+DESCRIPTION:
+{{.Tab1}}'{{.AppName}} {{.SubCmd}}' generates the files needed for testing with TLS,
+{{.Tab1}}in the PEM format: the certificate of a throwaway CA, '{{.DefaultReceiverCA}}', and
+{{.Tab1}}the certificates and private keys it issues for receivers, '{{.DefaultReceiverCert}}'
+{{.Tab1}}and '{{.DefaultReceiverKey}}', and for senders, '{{.DefaultClientCert}}' and
+{{.Tab1}}'{{.DefaultClientKey}}'. The private key of the CA is not kept: no other
+{{.Tab1}}certificate can be issued by it.
+{{.Tab1}}Receivers started in the directory of those files use them by default.
+{{.Tab1}}Senders verify receivers with '-ca {{.DefaultReceiverCA}}' and authenticate
+{{.Tab1}}with '-cert {{.DefaultClientCert}} -key {{.DefaultClientKey}}'.
+
+OPTIONS:
+{{.Tab1}}-dir <directory>
+{{.Tab2}}directory the files are written to.
+{{.Tab2}}Default: the current directory
+
+{{.Tab1}}-hosts <list>
+{{.Tab2}}comma-separated list of the host names and IP addresses the receiver
+{{.Tab2}}certificate is valid for, e.g. 'receiver.example.com,192.0.2.1'.
+{{.Tab2}}Default: 'localhost', the name of this host, '127.0.0.1' and '::1'
+
+{{.Tab1}}-client-hosts <list>
+{{.Tab2}}comma-separated list of the host names and IP addresses included
+{{.Tab2}}in the sender certificate.
+{{.Tab2}}Default: none
+
+{{.Tab1}}-key-type <type>
+{{.Tab2}}type of the keys of the CA and of the receiver: 'ecdsa-p256',
+{{.Tab2}}'ecdsa-p384', 'ecdsa-p521', 'ed25519', 'rsa-2048', 'rsa-3072' or
+{{.Tab2}}'rsa-4096'.
+{{.Tab2}}Default: '{{.DefaultKeyType}}'
+
+{{.Tab1}}-client-key-type <type>
+{{.Tab2}}type of the key of the sender, with the same values as '-key-type'.
+{{.Tab2}}Default: the value of '-key-type'
+
+{{.Tab1}}-validity <duration>
+{{.Tab2}}validity period of the certificates, e.g. '24h'.
+{{.Tab2}}Default: '{{.DefaultCertValidity}}'
+
+{{.Tab1}}-force
+{{.Tab2}}overwrite the files which already exist. By default, no file is
+{{.Tab2}}written if any of them exists.
+
+{{.Tab1}}-help
+{{.Tab2}}print this help
+`
+	tmplFields["SubCmd"] = cmd
+	tmplFields["SubCmdFiller"] = strings.Repeat(" ", len(cmd))
+	tmplFields["DefaultReceiverCert"] = defaultReceiverCert
+	tmplFields["DefaultReceiverKey"] = defaultReceiverKey
+	tmplFields["DefaultReceiverCA"] = defaultReceiverCA
+	tmplFields["DefaultClientCert"] = defaultClientCert
+	tmplFields["DefaultClientKey"] = defaultClientKey
+	tmplFields["DefaultKeyType"] = defaultKeyType
+	tmplFields["DefaultCertValidity"] = defaultCertValidity.String()
+	render(template, tmplFields, f)
+}
@@ -0,0 +1,34 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// file listing the congestion control algorithms available in the kernel
+const availableCongestionFile = "/proc/sys/net/ipv4/tcp_available_congestion_control"
+
+// availableCongestionControls returns the names of the TCP congestion
+// control algorithms available in the kernel
+func availableCongestionControls() ([]string, error) {
+	contents, err := os.ReadFile(availableCongestionFile)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(contents)), nil
+}
+
+// setCongestion sets the TCP congestion control algorithm of socket fd
+func setCongestion(fd uintptr, name string) error {
+	return unix.SetsockoptString(int(fd), unix.IPPROTO_TCP, unix.TCP_CONGESTION, name)
+}
+
+// getCongestion returns the TCP congestion control algorithm in use by
+// socket fd
+func getCongestion(fd uintptr) (string, error) {
+	return unix.GetsockoptString(int(fd), unix.IPPROTO_TCP, unix.TCP_CONGESTION)
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+// availableCongestionControls is only implemented on Linux
+func availableCongestionControls() ([]string, error) {
+	return nil, errNotSupported
+}
+
+// setCongestion is only implemented on Linux
+func setCongestion(fd uintptr, name string) error {
+	return errNotSupported
+}
+
+// getCongestion is only implemented on Linux
+func getCongestion(fd uintptr) (string, error) {
+	return "", errNotSupported
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// In handshake tests the sender measures the rate at which the receiver
+// completes TLS handshakes, instead of the throughput of data connections.
+// Every stream repeatedly establishes a connection, sends the header
+// attaching it to the test and waits for the receiver to close it. The
+// test runs in two phases of the requested duration: without and then
+// with TLS session resumption.
+
+const (
+	// delays before retrying after a failed connection, doubled after each
+	// consecutive failure
+	minHandshakeBackoff = 10 * time.Millisecond
+	maxHandshakeBackoff = time.Second
+)
+
+// isHandshakeScheme reports whether handshake tests are supported over
+// the transport of scheme, whose every connection starts with a TLS
+// handshake
+func isHandshakeScheme(scheme string) bool {
+	switch scheme {
+	case "tls", "https", "h2", "wss":
+		return true
+	}
+	return false
+}
+
+// handshakePhase holds the measurements made during one of the phases of
+// a handshake test
+type handshakePhase struct {
+	resumption bool
+	duration   time.Duration
+	latencies  []float64 // of the successful handshakes, in milliseconds
+	resumed    int       // number of handshakes which resumed a session
+	failed     int       // number of connections which could not be established
+	err        error     // first error, if any
+	tls        *tlsInfo  // session of the last successful handshake
+	mu         sync.Mutex
+}
+
+// add accounts for the measurements made by one of the streams
+func (p *handshakePhase) add(latencies []float64, resumed, failed int, err error, info *tlsInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latencies = append(p.latencies, latencies...)
+	p.resumed += resumed
+	p.failed += failed
+	if p.err == nil {
+		p.err = err
+	}
+	if info != nil {
+		p.tls = info
+	}
+}
+
+// rate returns the number of successful handshakes per second
+func (p *handshakePhase) rate() float64 {
+	return float64(len(p.latencies)) / p.duration.Seconds()
+}
+
+// percentile returns the p-th percentile of the handshake latencies, in
+// milliseconds, by the nearest rank method. The latencies must be sorted.
+func (p *handshakePhase) percentile(pct float64) float64 {
+	if len(p.latencies) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(pct / 100 * float64(len(p.latencies))))
+	if rank < 1 {
+		rank = 1
+	}
+	return p.latencies[rank-1]
+}
+
+// name returns the qualifier of the phase in the reports
+func (p *handshakePhase) name() string {
+	if p.resumption {
+		return "with resumption"
+	}
+	return "without resumption"
+}
+
+// handshakeRun runs a handshake test with numWorkers streams against the
+// receiver of config and reports its measurements
+func handshakeRun(config senderConfig, local *net.TCPAddr, sockopts *socketOptions, tlsConfig *tls.Config, numWorkers int, bufsize, msgsize int64) error {
+	scheme, _ := splitScheme(config.addr)
+	if !isHandshakeScheme(scheme) {
+		return fmt.Errorf("option -handshakes is only supported over TLS, HTTPS, HTTP/2 and secure WebSocket")
+	}
+	ctrl, session, err := openControl(getDialer(config.addr, local, sockopts, tlsConfig, int(msgsize), false), &testRequest{
+		Protocol:   protocolHandshake,
+		Mode:       modeSend,
+		Duration:   2 * config.duration,
+		BufferSize: bufsize,
+		Streams:    numWorkers,
+	})
+	if err != nil {
+		return err
+	}
+	defer ctrl.Close()
+
+	phases := make([]*handshakePhase, 0, 2)
+	for _, resumption := range []bool{false, true} {
+		phaseConfig := tlsConfig.Clone()
+		if resumption {
+			// Shared by all the streams, as by the connections of a client
+			phaseConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+		}
+		dial := getDialer(config.addr, local, sockopts, phaseConfig, int(msgsize), false)
+		phase := &handshakePhase{resumption: resumption}
+		start := time.Now()
+		deadline := start.Add(config.duration)
+		var wg sync.WaitGroup
+		wg.Add(numWorkers)
+		for i := 0; i < numWorkers; i++ {
+			go handshakeWorker(dial, session, i, deadline, phase, &wg)
+		}
+		wg.Wait()
+		phase.duration = time.Since(start)
+		sort.Float64s(phase.latencies)
+		phases = append(phases, phase)
+	}
+	if _, err := fetchResults(ctrl); err != nil {
+		errlog.Printf("could not notify receiver of the end of the test: %s\n", err)
+	}
+
+	if config.json {
+		if err := printJSON(newJSONHandshakeReport(config, numWorkers, phases), true); err != nil {
+			return err
+		}
+	} else {
+		printHandshakeSummary(config.duration, numWorkers, phases)
+	}
+	for _, p := range phases {
+		if len(p.latencies) == 0 && p.err != nil {
+			return p.err
+		}
+	}
+	return nil
+}
+
+// handshakeWorker establishes connections attached to the given stream of
+// session, one at a time, until deadline and records the measurements in
+// phase
+func handshakeWorker(dial func() (net.Conn, error), session string, stream int, deadline time.Time, phase *handshakePhase, wg *sync.WaitGroup) {
+	defer wg.Done()
+	var latencies []float64
+	var resumed, failed int
+	var firstErr error
+	var last *tlsInfo
+	backoff := minHandshakeBackoff
+	for time.Now().Before(deadline) {
+		info, err := handshake(dial, session, stream)
+		if err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			// Don't spin against a receiver which went away
+			wait := time.Until(deadline)
+			if wait > backoff {
+				wait = backoff
+			}
+			time.Sleep(wait)
+			if backoff *= 2; backoff > maxHandshakeBackoff {
+				backoff = maxHandshakeBackoff
+			}
+			continue
+		}
+		backoff = minHandshakeBackoff
+		latencies = append(latencies, info.Handshake)
+		if info.Resumed {
+			resumed++
+		}
+		last = info
+	}
+	phase.add(latencies, resumed, failed, firstErr, last)
+}
+
+// handshake establishes a connection attached to the given stream of
+// session and returns the TLS session negotiated once the receiver closed
+// the connection
+func handshake(dial func() (net.Conn, error), session string, stream int) (*tlsInfo, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	info := getTLSInfo(conn)
+	if info == nil {
+		return nil, fmt.Errorf("connection to %s is not secured with TLS", conn.RemoteAddr())
+	}
+	conn.SetDeadline(time.Now().Add(readGracePeriod))
+	if err := writeMessage(conn, &connHeader{Stream: &streamHeader{Session: session, Stream: stream}}); err != nil {
+		return nil, err
+	}
+	// The session tickets sent by the receiver after the handshake are
+	// only processed while reading: wait for the receiver to close the
+	// connection for them to be available to the next connections
+	io.Copy(io.Discard, conn)
+	return info, nil
+}
+
+// printHandshakeSummary prints the measurements of the phases of a
+// handshake test of the given duration per phase
+func printHandshakeSummary(duration time.Duration, numWorkers int, phases []*handshakePhase) {
+	lines := [][2]string{
+		{"mode:", "handshakes"},
+		{"duration per phase:", duration.String()},
+		{"streams:", fmt.Sprintf("%d", numWorkers)},
+	}
+	for _, p := range phases {
+		name := p.name()
+		lines = append(lines, [2]string{fmt.Sprintf("handshakes %s:", name),
+			fmt.Sprintf("%d in %s (%.2f/sec)", len(p.latencies), p.duration.Round(time.Millisecond), p.rate())})
+		if len(p.latencies) > 0 {
+			lines = append(lines, [2]string{fmt.Sprintf("latency %s:", name),
+				fmt.Sprintf("min/p50/p90/p99/max %.3f / %.3f / %.3f / %.3f / %.3f ms",
+					p.latencies[0], p.percentile(50), p.percentile(90), p.percentile(99), p.latencies[len(p.latencies)-1])})
+		}
+		if p.resumption {
+			lines = append(lines, [2]string{"sessions resumed:", fmt.Sprintf("%d of %d", p.resumed, len(p.latencies))})
+		}
+		if p.tls != nil {
+			lines = append(lines, [2]string{fmt.Sprintf("tls %s:", name), p.tls.String()})
+		}
+		if p.failed > 0 {
+			lines = append(lines, [2]string{fmt.Sprintf("failed connections %s:", name), fmt.Sprintf("%d (first error: %s)", p.failed, p.err)})
+		}
+	}
+	printLines(lines)
+}
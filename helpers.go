@@ -20,6 +20,10 @@ func setErrlog(cmd string) *log.Logger {
 //    1024K
 //    1024M
 //    1024MB
+//    1024G
+//    1024GB
+//    1024T
+//    1024TB
 // and returns the equivalent number of bytes
 func parseBufferLength(s string) (int64, error) {
 	if s == "" {
@@ -29,6 +33,9 @@ func parseBufferLength(s string) (int64, error) {
 	if s[len(s)-1] == 'B' {
 		s = s[:len(s)-1]
 	}
+	if s == "" {
+		return 0, fmt.Errorf("missing value")
+	}
 	factor := ByteSize(1)
 	switch s[len(s)-1] {
 	case 'K':
@@ -40,14 +47,33 @@ func parseBufferLength(s string) (int64, error) {
 	case 'G':
 		s = s[:len(s)-1]
 		factor = GB
+	case 'T':
+		s = s[:len(s)-1]
+		factor = TB
 	}
-	v, err := strconv.ParseInt(s, 10, 32)
+	v, err := strconv.ParseInt(s, 10, 64)
 	if err != nil {
 		return 0, err
 	}
+	if v < 0 {
+		return 0, fmt.Errorf("negative value %q", s)
+	}
+	if v > math.MaxInt64/int64(factor) {
+		return 0, fmt.Errorf("value %q out of range", s)
+	}
 	return v * int64(factor), nil
 }
 
+// splitScheme splits a network address of the form 'scheme://address'
+// into its scheme and address. The scheme of addresses without one is
+// "tcp".
+func splitScheme(addr string) (scheme, address string) {
+	if i := strings.Index(addr, "://"); i >= 0 {
+		return addr[:i], addr[i+3:]
+	}
+	return "tcp", addr
+}
+
 // stats returns the sum, average and standard deviation of a
 // slice of floats
 func stats(rates []float64) (sum, avg, std float64) {
@@ -61,3 +87,62 @@ func stats(rates []float64) (sum, avg, std float64) {
 	std = math.Sqrt(std / float64(len(rates)))
 	return
 }
+
+// parseRate parses a data rate in bits per second of any of the forms
+//    1000000
+//    1000K
+//    100M
+//    10G
+// optionally followed by 'bps' or 'bit/s'. The suffixes 'K', 'M', 'G' and
+// 'T' are understood as powers of 1000, as usual for network rates.
+// It returns the equivalent number of bits per second.
+func parseRate(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	s = strings.ToUpper(s)
+	for _, unit := range []string{"BIT/S", "BPS"} {
+		s = strings.TrimSuffix(s, unit)
+	}
+	if s == "" {
+		return 0, fmt.Errorf("missing value")
+	}
+	factor := float64(1)
+	switch s[len(s)-1] {
+	case 'K':
+		factor = 1e3
+	case 'M':
+		factor = 1e6
+	case 'G':
+		factor = 1e9
+	case 'T':
+		factor = 1e12
+	}
+	if factor > 1 {
+		s = s[:len(s)-1]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	return v * factor, nil
+}
+
+// containsString reports whether s is an element of list
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// fileExists reports whether a file exists at path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// In HTTP tests the sender establishes the control connection of the test
+// by upgrading an HTTP/1.1 connection to the netperf protocol, in the same
+// way WebSocket connections are established. The data of every stream is
+// then sent as the chunked body of a POST request: over its own connection
+// with HTTP/1.1 or as a stream of a single connection with HTTP/2.
+
+const (
+	// path of the requests for establishing a control connection
+	httpControlPath = "/netperf/control"
+
+	// path of the requests carrying the data of a stream
+	httpStreamPath = "/netperf/stream"
+
+	// protocol the control connections are upgraded to
+	httpUpgradeProtocol = "netperf"
+)
+
+// upgradeControl upgrades conn, an HTTP/1.1 connection to the receiver at
+// addr, to a control connection. scheme is "http" or "https".
+func upgradeControl(conn net.Conn, scheme, addr string) error {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s://%s%s", scheme, addr, httpControlPath), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", httpUpgradeProtocol)
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	// The receiver does not send anything after the response until it
+	// receives the test request, so no data is lost by buffering
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return fmt.Errorf("cannot establish control connection: receiver responded %q", resp.Status)
+	}
+	return nil
+}
+
+// httpStreamConn is used by the sender for sending the data of a stream
+// as the body of an HTTP request. Its local and remote addresses are those
+// of the connection the request is sent over.
+type httpStreamConn struct {
+	net.Conn  // the connection the request is sent over
+	transport *http.Transport
+	body      *io.PipeWriter
+	response  chan error // receives the outcome of the request
+}
+
+func (c *httpStreamConn) Write(b []byte) (int, error) {
+	return c.body.Write(b)
+}
+
+// Read is not supported: data is only sent
+func (c *httpStreamConn) Read(b []byte) (int, error) {
+	return 0, errNotSupported
+}
+
+// CloseWrite terminates the body of the request and waits for the
+// response of the receiver
+func (c *httpStreamConn) CloseWrite() error {
+	c.body.Close()
+	return <-c.response
+}
+
+func (c *httpStreamConn) Close() error {
+	c.body.CloseWithError(net.ErrClosed)
+	c.transport.CloseIdleConnections()
+	return c.Conn.Close()
+}
+
+// NetConn returns the connection the request is sent over
+func (c *httpStreamConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// isHTTPScheme reports whether scheme is the scheme of the addresses of
+// the HTTP transports
+func isHTTPScheme(scheme string) bool {
+	switch scheme {
+	case "http", "https", "h2", "h2c":
+		return true
+	}
+	return false
+}
+
+// httpClient sends the requests carrying the data of the streams of a test
+// to the receiver at addr. With HTTP/1.1 every request needs its own client,
+// so that it is sent over its own connection. With HTTP/2 the requests of
+// all the streams are sent concurrently over the single connection of the
+// client.
+type httpClient struct {
+	addr      string
+	transport *http.Transport
+	once      sync.Once
+	conn      net.Conn      // the connection of the client, once established
+	connected chan struct{} // closed once the connection is established
+}
+
+// newHTTPClient returns a client for sending requests to the receiver at
+// addr, of the form 'http://host:port', 'https://host:port',
+// 'h2://host:port' or 'h2c://host:port'. HTTPS requests use the TLS
+// configuration tlsConfig.
+func newHTTPClient(addr string, local *net.TCPAddr, opts *socketOptions, tlsConfig *tls.Config) *httpClient {
+	c := &httpClient{
+		addr:      addr,
+		connected: make(chan struct{}),
+	}
+	d := newDialer(local, opts)
+	established := func(conn net.Conn, err error) (net.Conn, error) {
+		if err == nil {
+			c.once.Do(func() {
+				c.conn = conn
+				close(c.connected)
+			})
+		}
+		return conn, err
+	}
+	config := tlsConfig.Clone()
+	config.NextProtos = []string{"http/1.1"}
+	c.transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return established(applyOptions(opts)(d.DialContext(ctx, opts.network("tcp"), address)))
+		},
+		// The handshake is performed by the client for measuring its duration
+		DialTLSContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return established(dialTLS(ctx, d, opts, opts.network("tcp"), address, config))
+		},
+		DisableCompression: true,
+		MaxConnsPerHost:    1,
+	}
+	switch scheme, _ := splitScheme(addr); scheme {
+	case "h2":
+		config.NextProtos = []string{"h2"}
+		c.transport.Protocols = new(http.Protocols)
+		c.transport.Protocols.SetHTTP2(true)
+	case "h2c":
+		// The receiver is known to support HTTP/2 without TLS
+		c.transport.Protocols = new(http.Protocols)
+		c.transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return c
+}
+
+// send sends the request for sending the data of the given stream of
+// a test. It returns once the connection of the client is established.
+func (c *httpClient) send(session string, stream int) (net.Conn, error) {
+	scheme, hostport := splitScheme(c.addr)
+	switch scheme {
+	case "h2":
+		scheme = "https"
+	case "h2c":
+		scheme = "http"
+	}
+	body, w := io.Pipe()
+	url := fmt.Sprintf("%s://%s%s?session=%s&stream=%d", scheme, hostport, httpStreamPath, session, stream)
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	sc := &httpStreamConn{
+		transport: c.transport,
+		body:      w,
+		response:  make(chan error, 1),
+	}
+	go func() {
+		resp, err := (&http.Client{Transport: c.transport}).Do(req)
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = fmt.Errorf("receiver responded %q", resp.Status)
+			}
+		}
+		if err != nil {
+			// Make pending and subsequent writes fail
+			body.CloseWithError(err)
+		}
+		sc.response <- err
+	}()
+	select {
+	case <-c.connected:
+		sc.Conn = c.conn
+		return sc, nil
+	case err := <-sc.response:
+		if err == nil {
+			err = fmt.Errorf("request completed before sending any data")
+		}
+		c.transport.CloseIdleConnections()
+		return nil, err
+	}
+}
+
+// connContextKey is the key of the connection of a request in its context
+type connContextKey struct{}
+
+// serveHTTP serves the requests of the senders accepted by listener, for
+// HTTP and WebSocket tests
+func serveHTTP(listener net.Listener, sockopts *socketOptions, config receiverConfig) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(httpControlPath, func(w http.ResponseWriter, r *http.Request) {
+		handleHTTPControl(w, r, config)
+	})
+	mux.HandleFunc(httpStreamPath, func(w http.ResponseWriter, r *http.Request) {
+		handleHTTPStream(w, r, config)
+	})
+	mux.HandleFunc(webSocketPath, func(w http.ResponseWriter, r *http.Request) {
+		handleWebSocket(w, r, config)
+	})
+	server := &http.Server{
+		Handler:   mux,
+		Protocols: new(http.Protocols),
+		ErrorLog:  errlog,
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			if err := sockopts.apply(conn); err != nil {
+				errlog.Printf("cannot set socket options of connection from %s: %s\n", conn.RemoteAddr(), err)
+			}
+			return context.WithValue(ctx, connContextKey{}, conn)
+		},
+	}
+	server.Protocols.SetHTTP1(true)
+	switch scheme, _ := splitScheme(config.addr); scheme {
+	case "h2":
+		server.Protocols.SetHTTP2(true)
+	case "h2c":
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return server.Serve(listener)
+}
+
+// handleHTTPControl upgrades the connection of r to the control connection
+// of a test
+func handleHTTPControl(w http.ResponseWriter, r *http.Request, config receiverConfig) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), httpUpgradeProtocol) {
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Upgrade", httpUpgradeProtocol)
+		http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be upgraded", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		errlog.Printf("cannot upgrade connection from %s: %s\n", r.RemoteAddr, err)
+		return
+	}
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	rw.WriteString("Connection: Upgrade\r\n")
+	rw.WriteString("Upgrade: " + httpUpgradeProtocol + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		errlog.Printf("cannot upgrade connection from %s: %s\n", r.RemoteAddr, err)
+		conn.Close()
+		return
+	}
+	handleConnection(conn, config)
+}
+
+// handleHTTPStream receives the data of a stream sent as the body of r
+// and records the measurements in the test session
+func handleHTTPStream(w http.ResponseWriter, r *http.Request, config receiverConfig) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	stream, err := strconv.Atoi(query.Get("stream"))
+	if err != nil {
+		http.Error(w, "invalid stream", http.StatusBadRequest)
+		return
+	}
+	s := lookupSession(query.Get("session"))
+	if s == nil {
+		errlog.Printf("unknown session %q for stream from %s\n", query.Get("session"), r.RemoteAddr)
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	conn := r.Context().Value(connContextKey{}).(net.Conn)
+	counters := s.addStream(stream, conn)
+	if info, err := getSocketInfo(conn); err == nil && config.socket.isSet() {
+		errlog.Printf("stream %d from %s: %s\n", stream, conn.RemoteAddr(), info)
+	}
+	received, err := receiveData(r.Body, s.test.Omit, &counters.received)
+	recordStream(s, stream, conn, transferStats{}, received, err, config)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
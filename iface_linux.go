@@ -0,0 +1,17 @@
+//go:build linux
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// bindToDevice binds socket fd to the network interface name, so that it
+// only sends and receives packets through it
+func bindToDevice(fd uintptr, name string) error {
+	return unix.BindToDevice(int(fd), name)
+}
+
+// boundDevice returns the name of the network interface socket fd is bound
+// to or an empty string if it is not bound to any
+func boundDevice(fd uintptr) (string, error) {
+	return unix.GetsockoptString(int(fd), unix.SOL_SOCKET, unix.SO_BINDTODEVICE)
+}
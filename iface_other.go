@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+// bindToDevice is only implemented on Linux
+func bindToDevice(fd uintptr, name string) error {
+	return errNotSupported
+}
+
+// boundDevice is only implemented on Linux
+func boundDevice(fd uintptr) (string, error) {
+	return "", errNotSupported
+}
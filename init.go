@@ -51,13 +51,20 @@ var (
 const (
 	receiveSubCmd       string        = "receive"
 	sendSubCmd          string        = "send"
+	certsSubCmd         string        = "certs"
 	defaultReceiverAddr string        = ":9876"
 	defaultReceiverCA   string        = "ca.pem"
 	defaultReceiverCert string        = "cert.pem"
 	defaultReceiverKey  string        = "key.pem"
+	defaultClientCert   string        = "client-cert.pem"
+	defaultClientKey    string        = "client-key.pem"
+	defaultKeyType      string        = "ecdsa-p256"
+	defaultCertValidity time.Duration = time.Duration(30*24) * time.Hour
 	defaultDuration     time.Duration = time.Duration(30) * time.Second
 	defaultParallel     int           = 1
 	defaultBufferSize   string        = "128KB"
+	defaultDatagramSize string        = "1460"
+	defaultUDPRate      string        = "1M"
 )
 
 func init() {
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// counter counts the bytes transferred by an ongoing transfer. It can be
+// safely read while the transfer is in progress.
+type counter struct {
+	n uint64
+}
+
+func (c *counter) add(n int) {
+	if c != nil {
+		atomic.AddUint64(&c.n, uint64(n))
+	}
+}
+
+func (c *counter) load() uint64 {
+	return atomic.LoadUint64(&c.n)
+}
+
+// streamCounters holds the counters of the data sent and received over
+// a single stream
+type streamCounters struct {
+	id       int
+	conn     net.Conn // if not nil, used for reporting TCP statistics
+	sent     counter
+	received counter
+}
+
+// intervalReporter periodically prints the throughput observed during
+// the last interval on each stream of a test and on all of them
+type intervalReporter struct {
+	interval  time.Duration
+	logger    *log.Logger
+	sending   bool
+	receiving bool
+	mu        sync.Mutex
+	streams   []*streamCounters
+	quit      chan struct{}
+	done      chan struct{}
+}
+
+// newIntervalReporter creates a reporter which prints its reports to
+// logger. sending and receiving tell which directions are to be reported.
+func newIntervalReporter(interval time.Duration, logger *log.Logger, sending, receiving bool) *intervalReporter {
+	return &intervalReporter{
+		interval:  interval,
+		logger:    logger,
+		sending:   sending,
+		receiving: receiving,
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// add registers the counters of a stream to be included in the reports
+func (r *intervalReporter) add(c *streamCounters) {
+	r.mu.Lock()
+	r.streams = append(r.streams, c)
+	r.mu.Unlock()
+}
+
+// start starts reporting in the background, until stop is called
+func (r *intervalReporter) start() {
+	go r.run()
+}
+
+// stop reports the last, possibly partial, interval and stops reporting
+func (r *intervalReporter) stop() {
+	close(r.quit)
+	<-r.done
+}
+
+func (r *intervalReporter) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	start := time.Now()
+	last := start
+	prevSent := make(map[*streamCounters]uint64)
+	prevReceived := make(map[*streamCounters]uint64)
+	prevRetransmits := make(map[*streamCounters]uint32)
+	for {
+		var now time.Time
+		stopping := false
+		select {
+		case now = <-ticker.C:
+		case <-r.quit:
+			now, stopping = time.Now(), true
+		}
+		if stopping && now.Sub(last) < r.interval/10 {
+			// Don't report an insignificant last interval
+			return
+		}
+		from, to := last.Sub(start).Seconds(), now.Sub(start).Seconds()
+		elapsed := now.Sub(last).Seconds()
+		r.mu.Lock()
+		streams := append([]*streamCounters(nil), r.streams...)
+		r.mu.Unlock()
+		sumSent, sumReceived := uint64(0), uint64(0)
+		for _, c := range streams {
+			sent, received := c.sent.load(), c.received.load()
+			deltaSent, deltaReceived := sent-prevSent[c], received-prevReceived[c]
+			prevSent[c], prevReceived[c] = sent, received
+			sumSent += deltaSent
+			sumReceived += deltaReceived
+			r.print(fmt.Sprintf("%3d", c.id), from, to, elapsed, deltaSent, deltaReceived)
+			if c.conn == nil {
+				continue
+			}
+			if info, err := getTCPInfo(c.conn); err == nil {
+				period := fmt.Sprintf("%.2f-%.2f sec", from, to)
+				r.logger.Printf("[%3d] tcp   %-17s rtt %.3f ms  rttvar %.3f ms  retransmits %d  cwnd %d\n",
+					c.id, period, info.RTT, info.RTTVar, info.Retransmits-prevRetransmits[c], info.SndCwnd)
+				prevRetransmits[c] = info.Retransmits
+			}
+		}
+		if len(streams) > 1 {
+			r.print("SUM", from, to, elapsed, sumSent, sumReceived)
+		}
+		last = now
+		if stopping {
+			return
+		}
+	}
+}
+
+func (r *intervalReporter) print(label string, from, to, elapsed float64, sent, received uint64) {
+	line := func(dir string, n uint64) {
+		volume := float64(n) / float64(MB)
+		rate := float64(0)
+		if elapsed > 0 {
+			rate = volume / elapsed
+		}
+		period := fmt.Sprintf("%.2f-%.2f sec", from, to)
+		r.logger.Printf("[%s] %s  %-17s %10.2f MiB  %10.2f MiB/sec\n", label, dir, period, volume, rate)
+	}
+	if r.sending {
+		line("send", sent)
+	}
+	if r.receiving {
+		line("recv", received)
+	}
+}
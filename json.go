@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// jsonTransfer is the JSON representation of the measurements of a one-way
+// data transfer
+type jsonTransfer struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Bytes      uint64    `json:"bytes"`
+	DataVolume float64   `json:"dataVolumeMiB"`
+	Throughput float64   `json:"throughputMiBps"`
+}
+
+// newJSONTransfer returns the JSON representation of s or nil if no
+// transfer took place
+func newJSONTransfer(s transferStats) *jsonTransfer {
+	if s.start.IsZero() {
+		return nil
+	}
+	return &jsonTransfer{
+		Start:      s.start,
+		End:        s.end,
+		Bytes:      s.bytes,
+		DataVolume: s.dataVolume(),
+		Throughput: s.throughput(),
+	}
+}
+
+// jsonDirection is the JSON representation of a directionSummary
+type jsonDirection struct {
+	DataVolume          float64 `json:"dataVolumeMiB"`
+	AggregateThroughput float64 `json:"aggregateThroughputMiBps"`
+	AvgStreamThroughput float64 `json:"avgStreamThroughputMiBps"`
+	StdStreamThroughput float64 `json:"stdStreamThroughputMiBps"`
+}
+
+func newJSONDirection(d directionSummary) *jsonDirection {
+	return &jsonDirection{
+		DataVolume:          d.dataVolume,
+		AggregateThroughput: d.aggregateThroughput,
+		AvgStreamThroughput: d.avgStreamThroughput,
+		StdStreamThroughput: d.stdStreamThroughput,
+	}
+}
+
+// jsonPeer holds the measurements made by one of the ends in each
+// direction. Directions not relevant for the mode of the test are omitted.
+type jsonPeer struct {
+	Sent     *jsonDirection `json:"sent,omitempty"`
+	Received *jsonDirection `json:"received,omitempty"`
+}
+
+// jsonSenderReport is the document printed by the sender when JSON output
+// is requested
+type jsonSenderReport struct {
+	Config  jsonSenderConfig   `json:"config"`
+	Summary jsonSummary        `json:"summary"`
+	Streams []jsonSenderStream `json:"streams"`
+}
+
+type jsonSenderConfig struct {
+	Addr       string  `json:"addr"`
+	Bind       string  `json:"bind,omitempty"`
+	Mode       string  `json:"mode"`
+	Duration   string  `json:"duration,omitempty"`
+	Bytes      int64   `json:"bytes,omitempty"`
+	Parallel   int     `json:"parallel"`
+	BufferSize int64   `json:"bufferSize"`
+	MsgSize    int64   `json:"messageSize,omitempty"` // only for WebSocket tests
+	Interval   string  `json:"interval,omitempty"`
+	Omit       string  `json:"omit,omitempty"`
+	Rate       float64 `json:"rateBps,omitempty"`
+	StreamRate bool    `json:"ratePerStream,omitempty"`
+}
+
+type jsonSummary struct {
+	Duration  float64        `json:"durationSec"`
+	Streams   int            `json:"streams"`
+	Conns     int            `json:"connections"`
+	Sender    jsonPeer       `json:"sender"`
+	Receiver  *jsonPeer      `json:"receiver,omitempty"`
+	Pacing    *jsonPacing    `json:"pacing,omitempty"`
+	Datagrams *jsonDatagrams `json:"datagrams,omitempty"`
+	Errors    []string       `json:"errors,omitempty"`
+}
+
+// jsonDatagrams is the JSON representation of a datagramSummary
+type jsonDatagrams struct {
+	Sent       uint64  `json:"sent"`
+	Received   uint64  `json:"received"`
+	Lost       uint64  `json:"lost"`
+	LossRatio  float64 `json:"lossRatio"`
+	OutOfOrder uint64  `json:"outOfOrder"`
+	Jitter     float64 `json:"jitterMs"`
+}
+
+func newJSONDatagrams(d datagramSummary) *jsonDatagrams {
+	return &jsonDatagrams{
+		Sent:       d.sent,
+		Received:   d.received,
+		Lost:       d.lost,
+		LossRatio:  d.lossRatio(),
+		OutOfOrder: d.outOfOrder,
+		Jitter:     d.jitter,
+	}
+}
+
+// jsonPacing is the JSON representation of a pacingReport
+type jsonPacing struct {
+	Target    float64 `json:"targetBps"`
+	Achieved  float64 `json:"achievedBps"`
+	Period    float64 `json:"periodSec"`
+	MinRate   float64 `json:"minRateBps"`
+	MaxRate   float64 `json:"maxRateBps"`
+	StdRate   float64 `json:"stdDeviationFromTargetBps"`
+	Samples   int     `json:"periods"`
+	OnTarget  int     `json:"periodsOnTarget"`
+	Tolerance float64 `json:"tolerance"`
+}
+
+type jsonSenderStream struct {
+	Stream     int                 `json:"stream"`
+	LocalAddr  string              `json:"localAddr"`
+	RemoteAddr string              `json:"remoteAddr"`
+	Start      time.Time           `json:"start"`
+	End        time.Time           `json:"end"`
+	Elapsed    float64             `json:"elapsedSec"`
+	Sent       *jsonTransfer       `json:"sent,omitempty"`
+	Received   *jsonTransfer       `json:"received,omitempty"`
+	TCPInfo    *tcpInfo            `json:"tcpInfo,omitempty"`
+	Socket     *socketInfo         `json:"socket,omitempty"`
+	TLS        *tlsInfo            `json:"tls,omitempty"`
+	Datagrams  *jsonDatagrams      `json:"datagrams,omitempty"`
+	Error      string              `json:"error,omitempty"`
+	Receiver   *jsonReceiverStream `json:"receiver,omitempty"`
+}
+
+// jsonReceiverStream holds the measurements made by the receiver on a
+// data connection. It is printed by the receiver for every connection when
+// JSON output is requested and included in the sender's report.
+type jsonReceiverStream struct {
+	Time       *time.Time     `json:"time,omitempty"`
+	Session    string         `json:"session,omitempty"`
+	Stream     int            `json:"stream"`
+	Mode       string         `json:"mode,omitempty"`
+	LocalAddr  string         `json:"localAddr,omitempty"`
+	RemoteAddr string         `json:"remoteAddr,omitempty"`
+	Sent       *jsonTransfer  `json:"sent,omitempty"`
+	Received   *jsonTransfer  `json:"received,omitempty"`
+	TCPInfo    *tcpInfo       `json:"tcpInfo,omitempty"`
+	Socket     *socketInfo    `json:"socket,omitempty"`
+	Datagrams  *datagramStats `json:"datagrams,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// newJSONPeer returns the JSON representation of the measurements made by
+// one of the ends in the directions relevant for the given mode
+func newJSONPeer(p peerSummary, sending, receiving bool) jsonPeer {
+	var jp jsonPeer
+	if sending {
+		jp.Sent = newJSONDirection(p.sent)
+	}
+	if receiving {
+		jp.Received = newJSONDirection(p.received)
+	}
+	return jp
+}
+
+// newJSONSenderReport builds the JSON document for the summary report of
+// a test run with the given configuration
+func newJSONSenderReport(config senderConfig, mode transferMode, bufsize, msgsize, volume int64, report summaryReport, results *testResults) *jsonSenderReport {
+	doc := &jsonSenderReport{
+		Config: jsonSenderConfig{
+			Addr:       config.addr,
+			Bind:       config.bind,
+			Mode:       mode.String(),
+			Bytes:      volume,
+			Parallel:   report.numWorkers,
+			BufferSize: bufsize,
+			MsgSize:    msgsize,
+		},
+		Summary: jsonSummary{
+			Duration: report.duration.Seconds(),
+			Streams:  report.numWorkers,
+			Conns:    report.numConns,
+			Sender:   newJSONPeer(peerSummary{sent: report.sent, received: report.received}, mode.senderWrites(), mode.senderReads()),
+		},
+		Streams: make([]jsonSenderStream, 0, len(report.responses)),
+	}
+	if volume == 0 {
+		doc.Config.Duration = config.duration.String()
+	}
+	if config.interval > 0 {
+		doc.Config.Interval = config.interval.String()
+	}
+	if config.omit > 0 {
+		doc.Config.Omit = config.omit.String()
+	}
+	if p := report.pacing; p != nil {
+		doc.Config.Rate = p.target
+		doc.Config.StreamRate = config.streamRate
+		doc.Summary.Pacing = &jsonPacing{
+			Target:    p.target,
+			Achieved:  p.achieved,
+			Period:    p.period.Seconds(),
+			MinRate:   p.minRate,
+			MaxRate:   p.maxRate,
+			StdRate:   p.stdRate,
+			Samples:   p.samples,
+			OnTarget:  p.onTarget,
+			Tolerance: p.tolerance,
+		}
+	}
+	if report.receiver != nil {
+		p := newJSONPeer(*report.receiver, mode.senderReads(), mode.senderWrites())
+		doc.Summary.Receiver = &p
+	}
+	if d := report.datagramTotal; d != nil {
+		doc.Summary.Datagrams = newJSONDatagrams(*d)
+	}
+	for _, err := range report.errors {
+		doc.Summary.Errors = append(doc.Summary.Errors, err.Error())
+	}
+	remote := make(map[int]streamResult)
+	if results != nil {
+		for _, r := range results.Streams {
+			remote[r.Stream] = r
+		}
+	}
+	for _, resp := range report.responses {
+		s := jsonSenderStream{
+			Stream:     resp.req.stream,
+			LocalAddr:  resp.req.conn.LocalAddr().String(),
+			RemoteAddr: resp.req.conn.RemoteAddr().String(),
+			Start:      resp.start,
+			End:        resp.end,
+			Elapsed:    resp.end.Sub(resp.start).Seconds(),
+			Sent:       newJSONTransfer(resp.sent),
+			Received:   newJSONTransfer(resp.received),
+			TCPInfo:    resp.tcpInfo,
+			Socket:     resp.socket,
+			TLS:        resp.tls,
+		}
+		if d, ok := report.datagrams[resp.req.stream]; ok {
+			s.Datagrams = newJSONDatagrams(d)
+		}
+		if resp.err != nil {
+			s.Error = resp.err.Error()
+		}
+		if r, ok := remote[resp.req.stream]; ok {
+			s.Receiver = &jsonReceiverStream{
+				Stream:     r.Stream,
+				LocalAddr:  r.LocalAddr,
+				RemoteAddr: r.RemoteAddr,
+				Sent:       newJSONTransfer(r.Sent.stats()),
+				Received:   newJSONTransfer(r.Received.stats()),
+				TCPInfo:    r.TCPInfo,
+				Socket:     r.Socket,
+				Datagrams:  r.Datagrams,
+				Error:      r.Error,
+			}
+		}
+		doc.Streams = append(doc.Streams, s)
+	}
+	sort.Slice(doc.Streams, func(i, j int) bool {
+		return doc.Streams[i].Stream < doc.Streams[j].Stream
+	})
+	return doc
+}
+
+// jsonHandshakeReport is the document printed by the sender at the end of
+// a handshake test when JSON output is requested
+type jsonHandshakeReport struct {
+	Config jsonSenderConfig     `json:"config"`
+	Phases []jsonHandshakePhase `json:"phases"`
+}
+
+// jsonHandshakePhase is the JSON representation of a handshakePhase
+type jsonHandshakePhase struct {
+	Resumption bool         `json:"resumption"`
+	Duration   float64      `json:"durationSec"`
+	Handshakes int          `json:"handshakes"`
+	Rate       float64      `json:"handshakesPerSec"`
+	Resumed    int          `json:"resumed"`
+	Latency    *jsonLatency `json:"latencyMs,omitempty"`
+	TLS        *tlsInfo     `json:"tls,omitempty"`
+	Failed     int          `json:"failed,omitempty"`
+	Error      string       `json:"error,omitempty"`
+}
+
+// jsonLatency holds the distribution of the handshake latencies of a phase
+type jsonLatency struct {
+	Min float64 `json:"min"`
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// newJSONHandshakeReport builds the JSON document for the report of a
+// handshake test run with the given configuration
+func newJSONHandshakeReport(config senderConfig, numWorkers int, phases []*handshakePhase) *jsonHandshakeReport {
+	doc := &jsonHandshakeReport{
+		Config: jsonSenderConfig{
+			Addr:     config.addr,
+			Bind:     config.bind,
+			Mode:     "handshakes",
+			Duration: config.duration.String(),
+			Parallel: numWorkers,
+		},
+		Phases: make([]jsonHandshakePhase, 0, len(phases)),
+	}
+	for _, p := range phases {
+		jp := jsonHandshakePhase{
+			Resumption: p.resumption,
+			Duration:   p.duration.Seconds(),
+			Handshakes: len(p.latencies),
+			Rate:       p.rate(),
+			Resumed:    p.resumed,
+			TLS:        p.tls,
+			Failed:     p.failed,
+		}
+		if n := len(p.latencies); n > 0 {
+			jp.Latency = &jsonLatency{
+				Min: p.latencies[0],
+				P50: p.percentile(50),
+				P90: p.percentile(90),
+				P99: p.percentile(99),
+				Max: p.latencies[n-1],
+			}
+		}
+		if p.err != nil {
+			jp.Error = p.err.Error()
+		}
+		doc.Phases = append(doc.Phases, jp)
+	}
+	return doc
+}
+
+var jsonMu sync.Mutex
+
+// printJSON writes the JSON encoding of v to the standard output. If indent
+// is false the encoding is written in a single line.
+func printJSON(v interface{}, indent bool) error {
+	jsonMu.Lock()
+	defer jsonMu.Unlock()
+	enc := json.NewEncoder(os.Stdout)
+	if indent {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(v)
+}
@@ -31,6 +31,7 @@ func main() {
 	commands := map[string]command{
 		receiveSubCmd: receiverCmd(),
 		sendSubCmd:    senderCmd(),
+		certsSubCmd:   certsCmd(),
 	}
 	cmd, ok := commands[args[0]]
 	if !ok {
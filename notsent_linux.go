@@ -0,0 +1,17 @@
+//go:build linux
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// setNotSentLowat limits the amount of unsent data queued in the send
+// buffer of socket fd
+func setNotSentLowat(fd uintptr, size int) error {
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_NOTSENT_LOWAT, size)
+}
+
+// getNotSentLowat returns the limit of unsent data queued in the send
+// buffer of socket fd
+func getNotSentLowat(fd uintptr) (int, error) {
+	return unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_NOTSENT_LOWAT)
+}
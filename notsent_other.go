@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+// setNotSentLowat is only implemented on Linux
+func setNotSentLowat(fd uintptr, size int) error {
+	return errNotSupported
+}
+
+// getNotSentLowat is only implemented on Linux
+func getNotSentLowat(fd uintptr) (int, error) {
+	return 0, errNotSupported
+}
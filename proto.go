@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// transferMode specifies in which direction data flows over a connection
+// established by the sender with the receiver
+type transferMode uint8
+
+const (
+	modeSend    transferMode = iota // sender writes, receiver reads
+	modeReverse                     // receiver writes, sender reads
+	modeBidir                       // both ends write and read simultaneously
+)
+
+func (m transferMode) String() string {
+	switch m {
+	case modeSend:
+		return "send"
+	case modeReverse:
+		return "reverse"
+	case modeBidir:
+		return "bidir"
+	}
+	return fmt.Sprintf("unknown (%d)", m)
+}
+
+// senderWrites reports whether the sender writes data to the connection
+// in this mode
+func (m transferMode) senderWrites() bool {
+	return m == modeSend || m == modeBidir
+}
+
+// senderReads reports whether the sender reads data from the connection
+// in this mode
+func (m transferMode) senderReads() bool {
+	return m == modeReverse || m == modeBidir
+}
+
+const (
+	// maximum size in bytes of an encoded protocol message
+	maxMessageSize = 64 * 1024
+
+	// maximum size of the buffer a sender can ask the receiver to use
+	maxBufferSize = 64 * int64(MB)
+
+	// maximum number of streams of a test
+	maxStreams = 1024
+
+	// extra time a peer waits for the other end to close the connection
+	// after the requested duration of a data exchange is over
+	readGracePeriod = 10 * time.Second
+)
+
+// connHeader is the first message sent by the sender over every
+// connection it establishes with the receiver. Exactly one of its fields
+// is set: Test for the control connection of a test and Stream for each
+// of its data connections.
+type connHeader struct {
+	Test   *testRequest  `json:"test,omitempty"`
+	Stream *streamHeader `json:"stream,omitempty"`
+}
+
+// testRequest describes the test the sender wants to run. It is sent
+// over the control connection, before any data connection is established.
+type testRequest struct {
+	Protocol   string        `json:"protocol,omitempty"` // protocolUDP, protocolHTTP, protocolHandshake or empty for streams
+	Mode       transferMode  `json:"mode"`
+	Duration   time.Duration `json:"duration"`
+	Bytes      int64         `json:"bytes,omitempty"` // if not zero, overrides Duration
+	Rate       float64       `json:"rate,omitempty"`  // bits/sec per stream, zero for unlimited
+	Omit       time.Duration `json:"omit,omitempty"`  // initial period excluded from measurements
+	BufferSize int64         `json:"bufferSize"`
+	Streams    int           `json:"streams"`
+}
+
+const (
+	// protocol of the tests whose data is sent as UDP datagrams instead of
+	// over data connections
+	protocolUDP = "udp"
+
+	// protocol of the tests whose data is sent as the body of HTTP requests
+	protocolHTTP = "http"
+
+	// protocol of the tests measuring the rate of TLS handshakes: no data
+	// is exchanged over their data connections
+	protocolHandshake = "handshake"
+)
+
+// streamBytes returns the number of bytes to be transferred in each
+// direction by the given stream of the test, or zero if the test is
+// limited by duration instead of by volume
+func (t *testRequest) streamBytes(stream int) int64 {
+	return streamShare(t.Bytes, t.Streams, stream)
+}
+
+// streamShare returns the share of total which corresponds to the
+// given stream when distributing it evenly among streams
+func streamShare(total int64, streams, stream int) int64 {
+	share := total / int64(streams)
+	if int64(stream) < total%int64(streams) {
+		share++
+	}
+	return share
+}
+
+// testAccept is the response of the receiver to a testRequest. Session
+// identifies the test in the headers of its data connections.
+type testAccept struct {
+	Session string `json:"session,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// streamHeader is sent by the sender over every data connection, before
+// any data is exchanged, to attach the connection to a test
+type streamHeader struct {
+	Session string `json:"session"`
+	Stream  int    `json:"stream"`
+}
+
+// testDone is sent by the sender over the control connection once all its
+// data connections are finished
+type testDone struct{}
+
+// testResults is the response of the receiver to testDone. It contains the
+// measurements made by the receiver on each data connection of the test.
+type testResults struct {
+	Streams []streamResult `json:"streams"`
+}
+
+// streamResult holds the measurements made by the receiver on a single
+// data connection
+type streamResult struct {
+	Stream     int            `json:"stream"`
+	LocalAddr  string         `json:"localAddr,omitempty"`
+	RemoteAddr string         `json:"remoteAddr,omitempty"`
+	Sent       transferRecord `json:"sent"`
+	Received   transferRecord `json:"received"`
+	TCPInfo    *tcpInfo       `json:"tcpInfo,omitempty"`
+	Socket     *socketInfo    `json:"socket,omitempty"`
+	Datagrams  *datagramStats `json:"datagrams,omitempty"` // only for UDP tests
+	Error      string         `json:"error,omitempty"`
+}
+
+// transferRecord is the encoded form of a transferStats
+type transferRecord struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Bytes uint64    `json:"bytes"`
+}
+
+func newTransferRecord(s transferStats) transferRecord {
+	return transferRecord{Start: s.start, End: s.end, Bytes: s.bytes}
+}
+
+func (r transferRecord) stats() transferStats {
+	return transferStats{start: r.Start, end: r.End, bytes: r.Bytes}
+}
+
+// writeMessage encodes v as JSON and writes it to w, prefixed by its
+// length as a 4-byte big-endian integer
+func writeMessage(w io.Writer, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxMessageSize {
+		return fmt.Errorf("protocol message too long (%d bytes)", len(payload))
+	}
+	msg := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(msg, uint32(len(payload)))
+	copy(msg[4:], payload)
+	_, err = w.Write(msg)
+	return err
+}
+
+// readMessage reads a message written by writeMessage from r and
+// decodes it into v
+func readMessage(r io.Reader, v interface{}) error {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return err
+	}
+	length := binary.BigEndian.Uint32(prefix[:])
+	if length > maxMessageSize {
+		return fmt.Errorf("protocol message too long (%d bytes)", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, v)
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// In QUIC tests every connection of the test, including the control
+// connection, is a bidirectional QUIC stream. The streams are multiplexed
+// over a single QUIC connection, unless the sender requests a connection
+// per stream.
+
+const (
+	// protocol negotiated by the QUIC connections via ALPN
+	quicALPN = "netperf"
+
+	// maximum number of streams a sender can open over a QUIC connection:
+	// the control stream and the data streams of a test
+	maxQUICStreams = maxStreams + 1
+
+	// period of the packets keeping idle QUIC connections alive, such as
+	// the connection of the control stream of a test whose data streams
+	// use their own connections
+	quicKeepAlivePeriod = 10 * time.Second
+)
+
+// quicStreamConn exposes a QUIC stream as a network connection. Its local
+// and remote addresses are those of the QUIC connection it belongs to.
+type quicStreamConn struct {
+	*quic.Stream
+	conn      *quic.Conn
+	udpConn   net.Conn      // socket the QUIC connection runs over
+	handshake time.Duration // duration of the handshake of the connection, if measured
+	onClose   func() error  // if not nil, called once the stream is closed
+}
+
+func (c *quicStreamConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *quicStreamConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// CloseWrite closes the sending side of the stream: the peer reads an end
+// of stream once all the data written is delivered
+func (c *quicStreamConn) CloseWrite() error {
+	return c.Stream.Close()
+}
+
+// Close closes both sides of the stream. The QUIC connection remains open
+// for delivering the data not yet acknowledged by the peer.
+func (c *quicStreamConn) Close() error {
+	c.CancelRead(0)
+	err := c.Stream.Close()
+	if c.onClose != nil {
+		return c.onClose()
+	}
+	return err
+}
+
+func (c *quicStreamConn) tlsInfo() *tlsInfo {
+	return newTLSInfo(c.conn.ConnectionState().TLS, c.handshake)
+}
+
+// NetConn returns the UDP socket the stream is sent over
+func (c *quicStreamConn) NetConn() net.Conn {
+	return c.udpConn
+}
+
+// quicClient opens the QUIC streams of a test with the receiver at addr.
+// Its connections are closed once the first stream it opened, the control
+// connection of the test, is closed.
+type quicClient struct {
+	addr          string
+	local         *net.UDPAddr
+	opts          *socketOptions
+	config        *tls.Config
+	connPerStream bool
+
+	mu         sync.Mutex
+	conns      []*quic.Conn
+	udpConns   []*net.UDPConn
+	handshakes []time.Duration
+}
+
+// newQUICClient returns a client for opening streams with the receiver at
+// addr, of the form 'host:port'. If connPerStream is set, each stream but
+// the first one is opened over its own connection.
+func newQUICClient(addr string, local *net.TCPAddr, opts *socketOptions, config *tls.Config, connPerStream bool) *quicClient {
+	c := &quicClient{
+		addr:          addr,
+		opts:          opts,
+		config:        config.Clone(),
+		connPerStream: connPerStream,
+	}
+	c.config.NextProtos = []string{quicALPN}
+	if c.config.ServerName == "" {
+		// Unlike TLS connections, QUIC connections are established to an
+		// address without a host name
+		c.config.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	if local != nil {
+		c.local = &net.UDPAddr{IP: local.IP, Zone: local.Zone}
+	}
+	return c
+}
+
+// open opens a stream with the receiver
+func (c *quicClient) open() (net.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	first := len(c.conns) == 0
+	if first || c.connPerStream {
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	last := len(c.conns) - 1
+	stream, err := c.conns[last].OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sc := &quicStreamConn{Stream: stream, conn: c.conns[last], udpConn: c.udpConns[last], handshake: c.handshakes[last]}
+	if first {
+		sc.onClose = c.close
+	}
+	return sc, nil
+}
+
+// connect establishes a new connection with the receiver, from its own
+// UDP socket
+func (c *quicClient) connect(ctx context.Context) error {
+	network := c.opts.network("udp")
+	raddr, err := net.ResolveUDPAddr(network, c.addr)
+	if err != nil {
+		return err
+	}
+	lc := &net.ListenConfig{
+		Control: c.opts.control,
+	}
+	laddr := ""
+	if c.local != nil {
+		laddr = c.local.String()
+	}
+	pconn, err := lc.ListenPacket(ctx, network, laddr)
+	if err != nil {
+		return err
+	}
+	udpConn := pconn.(*net.UDPConn)
+	start := time.Now()
+	conn, err := quic.Dial(ctx, udpConn, raddr, c.config, &quic.Config{
+		KeepAlivePeriod: quicKeepAlivePeriod,
+	})
+	if err != nil {
+		udpConn.Close()
+		return err
+	}
+	// The handshake establishes the connection
+	c.conns = append(c.conns, conn)
+	c.udpConns = append(c.udpConns, udpConn)
+	c.handshakes = append(c.handshakes, time.Since(start))
+	return nil
+}
+
+// close closes all the connections of the client
+func (c *quicClient) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, conn := range c.conns {
+		conn.CloseWithError(0, "")
+		c.udpConns[i].Close()
+	}
+	c.conns, c.udpConns, c.handshakes = nil, nil, nil
+	return nil
+}
+
+// quicListener accepts the streams opened by the senders over the QUIC
+// connections they establish
+type quicListener struct {
+	listener *quic.Listener
+	udpConn  *net.UDPConn
+	streams  chan net.Conn
+	done     chan struct{} // closed when connections can no longer be accepted
+	err      error
+}
+
+// listenQUIC returns a listener of the QUIC streams opened by senders
+// over connections established with addr, secured with config
+func listenQUIC(lc *net.ListenConfig, sockopts *socketOptions, addr string, config *tls.Config) (net.Listener, error) {
+	pconn, err := lc.ListenPacket(context.Background(), sockopts.network("udp"), addr)
+	if err != nil {
+		return nil, err
+	}
+	config = config.Clone()
+	config.NextProtos = []string{quicALPN}
+	listener, err := quic.Listen(pconn, config, &quic.Config{
+		MaxIncomingStreams: maxQUICStreams,
+		KeepAlivePeriod:    quicKeepAlivePeriod,
+	})
+	if err != nil {
+		pconn.Close()
+		return nil, err
+	}
+	l := &quicListener{
+		listener: listener,
+		udpConn:  pconn.(*net.UDPConn),
+		streams:  make(chan net.Conn),
+		done:     make(chan struct{}),
+	}
+	go l.acceptConns()
+	return l, nil
+}
+
+func (l *quicListener) acceptConns() {
+	for {
+		conn, err := l.listener.Accept(context.Background())
+		if err != nil {
+			l.err = err
+			close(l.done)
+			return
+		}
+		go l.acceptStreams(conn)
+	}
+}
+
+// acceptStreams accepts the streams of conn until it is closed
+func (l *quicListener) acceptStreams(conn *quic.Conn) {
+	for {
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		l.streams <- &quicStreamConn{Stream: stream, conn: conn, udpConn: l.udpConn}
+	}
+}
+
+func (l *quicListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.streams:
+		return conn, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+func (l *quicListener) Close() error {
+	err := l.listener.Close()
+	l.udpConn.Close()
+	return err
+}
+
+func (l *quicListener) Addr() net.Addr {
+	return l.listener.Addr()
+}
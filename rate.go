@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// rateTracker periodically samples the amount of data transferred by a
+// paced data exchange, for evaluating how closely the achieved rate
+// tracks the target rate over time
+type rateTracker struct {
+	period time.Duration
+	omit   time.Duration // initial period which is not sampled
+	sample func() uint64 // returns the number of bytes transferred so far
+	rates  []float64     // rate in bits/sec observed in each period
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+func newRateTracker(period, omit time.Duration, sample func() uint64) *rateTracker {
+	return &rateTracker{
+		period: period,
+		omit:   omit,
+		sample: sample,
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// start starts sampling in the background, until stop is called
+func (t *rateTracker) start() {
+	go t.run()
+}
+
+// stop stops sampling. The last period is taken into account only if it
+// lasted at least half the sampling period.
+func (t *rateTracker) stop() {
+	close(t.quit)
+	<-t.done
+}
+
+func (t *rateTracker) run() {
+	defer close(t.done)
+	if t.omit > 0 {
+		// As the other measurements, exclude the warm-up period
+		select {
+		case <-time.After(t.omit):
+		case <-t.quit:
+			return
+		}
+	}
+	ticker := time.NewTicker(t.period)
+	defer ticker.Stop()
+	last, prev := time.Now(), t.sample()
+	for {
+		var now time.Time
+		stopping := false
+		select {
+		case now = <-ticker.C:
+		case <-t.quit:
+			now, stopping = time.Now(), true
+		}
+		elapsed := now.Sub(last)
+		if stopping && elapsed < t.period/2 {
+			return
+		}
+		cur := t.sample()
+		t.rates = append(t.rates, float64((cur-prev)*8)/elapsed.Seconds())
+		last, prev = now, cur
+		if stopping {
+			return
+		}
+	}
+}
+
+// pacingReport summarizes how closely the achieved rate of a paced data
+// exchange tracked its target rate. All rates are in bits/sec.
+type pacingReport struct {
+	target    float64
+	achieved  float64
+	period    time.Duration
+	minRate   float64
+	maxRate   float64
+	stdRate   float64 // standard deviation of the per-period rates from target
+	samples   int
+	onTarget  int // number of periods within tolerance of the target
+	tolerance float64
+}
+
+// report builds the pacing report of the tracked exchange, given its target
+// and its achieved average rate
+func (t *rateTracker) report(target, achieved float64) *pacingReport {
+	const tolerance = 0.05
+	r := &pacingReport{
+		target:    target,
+		achieved:  achieved,
+		period:    t.period,
+		samples:   len(t.rates),
+		tolerance: tolerance,
+	}
+	for i, rate := range t.rates {
+		if i == 0 || rate < r.minRate {
+			r.minRate = rate
+		}
+		if i == 0 || rate > r.maxRate {
+			r.maxRate = rate
+		}
+		r.stdRate += (rate - target) * (rate - target)
+		if math.Abs(rate-target) <= tolerance*target {
+			r.onTarget++
+		}
+	}
+	if r.samples > 0 {
+		r.stdRate = math.Sqrt(r.stdRate / float64(r.samples))
+	}
+	return r
+}
@@ -1,12 +1,11 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net"
 	"os"
 	"strings"
@@ -17,12 +16,18 @@ import (
 
 type receiverConfig struct {
 	// Command line options
-	help    bool
-	addr    string
-	ca      string
-	cert    string
-	key     string
-	profile bool
+	help       bool
+	addr       string
+	ca         string
+	cert       string
+	key        string
+	keyType    string
+	clientCert bool
+	interval   time.Duration
+	json       bool
+	socket     socketConfig
+	tls        tlsFlags
+	profile    bool
 }
 
 func receiverCmd() command {
@@ -33,6 +38,12 @@ func receiverCmd() command {
 	fset.StringVar(&config.ca, "ca", defaultReceiverCA, "")
 	fset.StringVar(&config.cert, "cert", defaultReceiverCert, "")
 	fset.StringVar(&config.key, "key", defaultReceiverKey, "")
+	fset.StringVar(&config.keyType, "key-type", defaultKeyType, "")
+	fset.BoolVar(&config.clientCert, "require-client-cert", false, "")
+	fset.DurationVar(&config.interval, "interval", 0, "")
+	fset.BoolVar(&config.json, "json", false, "")
+	config.socket.registerFlags(fset)
+	config.tls.registerFlags(fset)
 	fset.BoolVar(&config.profile, "prof", false, "")
 	run := func(args []string) error {
 		fset.Usage = func() {
@@ -54,7 +65,14 @@ func receiverRun(cmdName string, config receiverConfig) error {
 		return nil
 	}
 	errlog = setErrlog(cmdName)
-	listener, err := listen(config)
+	if config.interval < 0 {
+		return fmt.Errorf("invalid interval value %s", config.interval)
+	}
+	sockopts, err := config.socket.options()
+	if err != nil {
+		return err
+	}
+	listener, err := listen(config, sockopts)
 	if err != nil {
 		return err
 	}
@@ -64,100 +82,462 @@ func receiverRun(cmdName string, config receiverConfig) error {
 		defer profile.Start(profile.ProfilePath("./pprof")).Stop()
 	}
 
+	if scheme, _ := splitScheme(config.addr); isHTTPScheme(scheme) || isWebSocketScheme(scheme) {
+		return serveHTTP(listener, sockopts, config)
+	}
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
 			errlog.Printf("%s\n", err)
 			continue
 		}
-		go receiveData(conn)
+		if err := sockopts.apply(conn); err != nil {
+			errlog.Printf("cannot set socket options of connection from %s: %s\n", conn.RemoteAddr(), err)
+		}
+		go handleConnection(conn, config)
 	}
 	return nil
 }
 
-func listen(config receiverConfig) (net.Listener, error) {
-	const prefix = "tls://"
-	if !strings.HasPrefix(config.addr, prefix) {
-		return net.Listen("tcp", config.addr)
+func listen(config receiverConfig, sockopts *socketOptions) (net.Listener, error) {
+	// Options set on the listening socket are inherited by the accepted ones
+	lc := &net.ListenConfig{
+		Control: sockopts.control,
+	}
+	scheme, addr := splitScheme(config.addr)
+	switch scheme {
+	case "tcp", "http", "h2c", "ws":
+		return lc.Listen(context.Background(), sockopts.network("tcp"), addr)
+	case "udp":
+		return listenUDP(lc, sockopts, addr)
+	case "unix":
+		return listenUnix(lc, addr)
+	case "tls", "https", "h2", "wss", "quic":
+	default:
+		return nil, fmt.Errorf("unsupported network address %q", config.addr)
 	}
-	pool, err := loadCaCerts(config.ca)
+	ca := config.ca
+	if ca == defaultReceiverCA && !fileExists(ca) {
+		ca = ""
+	}
+	pool, err := loadCaCerts(ca)
 	if err != nil {
-		return nil, fmt.Errorf("error loading CA certificate from file %q: %s", config.ca, err)
+		return nil, fmt.Errorf("error loading CA certificate from file %q: %s", ca, err)
+	}
+	if pool == nil && config.clientCert {
+		return nil, fmt.Errorf("option -require-client-cert requires -ca")
 	}
-	serverCert, err := tls.LoadX509KeyPair(config.cert, config.key)
+	serverCert, err := serverCertificate(config)
 	if err != nil {
-		return nil, fmt.Errorf("error loading server certificate from files %q and %q: %s", config.cert, config.key, err)
+		return nil, err
 	}
 	tlsConfig := &tls.Config{
 		ClientCAs:    pool,
 		Certificates: []tls.Certificate{serverCert},
 		ClientAuth:   tls.VerifyClientCertIfGiven,
 	}
-	config.addr = strings.TrimPrefix(config.addr, prefix)
-	return tls.Listen("tcp", config.addr, tlsConfig)
+	switch {
+	case pool == nil:
+		// Without CA certificates, crypto/tls would verify the client
+		// certificates against the system roots
+		tlsConfig.ClientAuth = tls.NoClientCert
+	case config.clientCert:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if err := config.tls.apply(tlsConfig); err != nil {
+		return nil, err
+	}
+	switch scheme {
+	case "https", "wss":
+		tlsConfig.NextProtos = []string{"http/1.1"}
+	case "h2":
+		// Control connections use HTTP/1.1
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	case "quic":
+		return listenQUIC(lc, sockopts, addr, tlsConfig)
+	}
+	listener, err := lc.Listen(context.Background(), sockopts.network("tcp"), addr)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(listener, tlsConfig), nil
 }
 
-func loadCaCerts(path string) (*x509.CertPool, error) {
-	if path == "" {
-		return nil, nil
+// serverCertificate returns the certificate the receiver presents to the
+// senders. If the default certificate and key files don't exist, a
+// self-signed certificate is generated and its fingerprint is printed,
+// for the senders to pin it.
+func serverCertificate(config receiverConfig) (tls.Certificate, error) {
+	if config.cert == defaultReceiverCert && config.key == defaultReceiverKey && !fileExists(config.cert) && !fileExists(config.key) {
+		if !containsString(keyTypes, config.keyType) {
+			return tls.Certificate{}, fmt.Errorf("unknown key type %q (valid types: %s)", config.keyType, strings.Join(keyTypes, ", "))
+		}
+		cert, err := selfSignedCert(config.keyType)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("cannot generate self-signed certificate: %s", err)
+		}
+		fp := fingerprint(cert.Certificate[0])
+		errlog.Printf("files %q and %q not found: using a self-signed %s certificate\n", config.cert, config.key, config.keyType)
+		errlog.Printf("certificate SHA-256 fingerprint: %s\n", fp)
+		errlog.Printf("senders can verify it with '-pin %s'\n", fp)
+		return cert, nil
 	}
-	caCerts, err := ioutil.ReadFile(path)
+	cert, err := tls.LoadX509KeyPair(config.cert, config.key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("error loading server certificate from files %q and %q: %s", config.cert, config.key, err)
+	}
+	return cert, nil
+}
+
+// listenUnix returns a listener of the Unix domain socket at path. A socket
+// left over at that path by a receiver which is no longer running is
+// removed beforehand.
+func listenUnix(lc *net.ListenConfig, path string) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another receiver is listening on %q", path)
+		}
+		os.Remove(path)
+	}
+	return lc.Listen(context.Background(), "unix", path)
+}
+
+// listenUDP starts receiving the datagrams of UDP tests at addr and returns
+// the listener for their control connections, on the same port
+func listenUDP(lc *net.ListenConfig, sockopts *socketOptions, addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
 	if err != nil {
 		return nil, err
 	}
-	pool := x509.NewCertPool()
-	if !pool.AppendCertsFromPEM(caCerts) {
+	conn, err := lc.ListenPacket(context.Background(), sockopts.network("udp"), addr)
+	if err != nil {
 		return nil, err
 	}
-	return pool, nil
+	_, port, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err == nil {
+		var listener net.Listener
+		listener, err = lc.Listen(context.Background(), sockopts.network("tcp"), net.JoinHostPort(host, port))
+		if err == nil {
+			go receiveDatagrams(conn)
+			return listener, nil
+		}
+	}
+	conn.Close()
+	return nil, err
 }
 
-func receiveData(conn net.Conn) {
+// handleConnection reads the header sent by the sender over conn and
+// handles the connection as either a control or a data connection
+func handleConnection(conn net.Conn, config receiverConfig) {
 	defer conn.Close()
-	received := uint64(0)
-	buffer := make([]byte, 256*1024)
-	start := time.Now()
-	for {
-		n, err := conn.Read(buffer[:])
-		received += uint64(n)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
+	var header connHeader
+	if err := readMessage(conn, &header); err != nil {
+		errlog.Printf("error reading header from %s: %s\n", conn.RemoteAddr(), err)
+		return
+	}
+	switch {
+	case header.Test != nil:
+		handleControl(conn, *header.Test, config)
+	case header.Stream != nil:
+		handleStream(conn, *header.Stream, config)
+	default:
+		errlog.Printf("invalid header received from %s\n", conn.RemoteAddr())
+	}
+}
+
+// handleControl registers a session for the test requested by the sender
+// and, once the sender is done, sends back the measurements made on every
+// data connection of the test
+func handleControl(conn net.Conn, test testRequest, config receiverConfig) {
+	scheme, _ := splitScheme(config.addr)
+	if err := validateTest(test, scheme); err != nil {
+		errlog.Printf("rejecting test requested by %s: %s\n", conn.RemoteAddr(), err)
+		writeMessage(conn, &testAccept{Error: err.Error()})
+		return
+	}
+	// The reporter is set before the session is registered, as its streams
+	// may then be handled concurrently
+	var reporter *intervalReporter
+	if config.interval > 0 {
+		reporter = newIntervalReporter(config.interval, errlog, test.Mode.senderReads(), test.Mode.senderWrites())
+	}
+	s, err := newSession(test, reporter)
+	if err != nil {
+		errlog.Printf("%s\n", err)
+		writeMessage(conn, &testAccept{Error: err.Error()})
+		return
+	}
+	defer s.unregister()
+	if reporter != nil {
+		reporter.start()
+		defer reporter.stop()
+	}
+	if err := writeMessage(conn, &testAccept{Session: s.id}); err != nil {
+		errlog.Printf("%s\n", err)
+		return
+	}
+	var done testDone
+	if err := readMessage(conn, &done); err != nil {
+		errlog.Printf("error reading from control connection with %s: %s\n", conn.RemoteAddr(), err)
+		return
+	}
+	if test.Protocol == protocolHandshake {
+		errlog.Printf("handshake test from %s: %d connections accepted\n", conn.RemoteAddr(), s.handshakeCount())
+		if err := writeMessage(conn, &testResults{}); err != nil {
 			errlog.Printf("%s\n", err)
-			return
+		}
+		return
+	}
+	if test.Protocol == protocolUDP {
+		time.Sleep(datagramGracePeriod)
+		for _, result := range s.datagramResults() {
+			s.addResult(result)
+			if result.Error != "" {
+				errlog.Printf("stream %d: %s\n", result.Stream, result.Error)
+			} else {
+				d := result.Datagrams
+				errlog.Printf("stream %d from %s: %d datagrams, %d out of order, jitter %.3f ms, throughput: %.2f MiB/sec\n",
+					result.Stream, result.RemoteAddr, d.Received, d.OutOfOrder, d.Jitter, result.Received.stats().throughput())
+			}
+			if config.json {
+				printStreamJSON(s, result)
+			}
 		}
 	}
-	elapsed := time.Since(start)
-	rate := float64(received) / float64(MB) / elapsed.Seconds()
-	errlog.Printf("throughput: %.2f MiB/sec\n", rate)
+	results := s.wait(readGracePeriod)
+	if err := writeMessage(conn, &testResults{Streams: results}); err != nil {
+		errlog.Printf("%s\n", err)
+	}
+}
+
+// validateTest checks that the parameters of a test requested by a sender
+// are acceptable. scheme is the scheme of the address the receiver
+// listens to.
+func validateTest(test testRequest, scheme string) error {
+	switch test.Mode {
+	case modeSend, modeReverse, modeBidir:
+	default:
+		return fmt.Errorf("unsupported mode %s", test.Mode)
+	}
+	switch test.Protocol {
+	case "":
+	case protocolUDP:
+		if scheme != "udp" {
+			return fmt.Errorf("UDP not enabled on this receiver")
+		}
+		if test.Mode != modeSend {
+			return fmt.Errorf("unsupported mode %s for UDP", test.Mode)
+		}
+		if test.BufferSize < datagramHeaderSize || test.BufferSize > maxDatagramSize {
+			return fmt.Errorf("invalid datagram size %d", test.BufferSize)
+		}
+	case protocolHTTP:
+		if !isHTTPScheme(scheme) {
+			return fmt.Errorf("HTTP not enabled on this receiver")
+		}
+		if test.Mode != modeSend {
+			return fmt.Errorf("unsupported mode %s for HTTP", test.Mode)
+		}
+	case protocolHandshake:
+		if !isHandshakeScheme(scheme) {
+			return fmt.Errorf("handshake tests not supported by this receiver")
+		}
+		if test.Mode != modeSend {
+			return fmt.Errorf("unsupported mode %s for handshake tests", test.Mode)
+		}
+	default:
+		return fmt.Errorf("unsupported protocol %q", test.Protocol)
+	}
+	if test.BufferSize <= 0 || test.BufferSize > maxBufferSize {
+		return fmt.Errorf("invalid buffer size %d", test.BufferSize)
+	}
+	if test.Streams <= 0 || test.Streams > maxStreams {
+		return fmt.Errorf("invalid number of streams %d", test.Streams)
+	}
+	if test.Bytes < 0 || (test.Bytes == 0 && test.Duration <= 0) {
+		return fmt.Errorf("invalid duration %s or volume %d", test.Duration, test.Bytes)
+	}
+	if test.Bytes > 0 && test.Bytes < int64(test.Streams) {
+		// A share of zero bytes would mean a transfer limited by duration
+		return fmt.Errorf("volume %d smaller than the number of streams %d", test.Bytes, test.Streams)
+	}
+	if test.Rate < 0 {
+		return fmt.Errorf("invalid rate %.0f bits/sec", test.Rate)
+	}
+	if test.Omit < 0 {
+		return fmt.Errorf("invalid omit period %s", test.Omit)
+	}
+	return nil
+}
+
+// handleStream performs over conn the data exchange specified by the test
+// the stream belongs to and records the measurements in the test session
+func handleStream(conn net.Conn, header streamHeader, config receiverConfig) {
+	s := lookupSession(header.Session)
+	if s == nil {
+		errlog.Printf("unknown session %q for stream from %s\n", header.Session, conn.RemoteAddr())
+		return
+	}
+	if s.test.Protocol == protocolHandshake {
+		// The sender waits for the connection to be closed
+		s.addHandshake()
+		return
+	}
+	counters := s.addStream(header.Stream, conn)
+	if info, err := getSocketInfo(conn); err == nil && config.socket.isSet() {
+		errlog.Printf("stream %d from %s: %s\n", header.Stream, conn.RemoteAddr(), info)
+	}
+	var sent, received transferStats
+	var sendErr, recvErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if s.test.Mode.senderWrites() {
+			received, recvErr = receiveData(conn, s.test.Omit, &counters.received)
+		}
+	}()
+	if s.test.Mode.senderReads() {
+		sent, sendErr = sendData(conn, transmission{
+			buffer:   s.sendBuffer(),
+			duration: s.test.Duration,
+			volume:   s.test.streamBytes(header.Stream),
+			rate:     s.test.Rate,
+			omit:     s.test.Omit,
+		}, &counters.sent)
+		closeWrite(conn)
+	}
+	<-done
+	err := sendErr
+	if err == nil {
+		err = recvErr
+	}
+	recordStream(s, header.Stream, conn, sent, received, err, config)
+}
+
+// recordStream records in session s the measurements made on the given
+// stream, whose data was exchanged over conn
+func recordStream(s *session, stream int, conn net.Conn, sent, received transferStats, err error, config receiverConfig) {
+	result := streamResult{
+		Stream:     stream,
+		LocalAddr:  conn.LocalAddr().String(),
+		RemoteAddr: conn.RemoteAddr().String(),
+		Sent:       newTransferRecord(sent),
+		Received:   newTransferRecord(received),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if info, err := getTCPInfo(conn); err == nil {
+		result.TCPInfo = info
+	}
+	if info, err := getSocketInfo(conn); err == nil {
+		result.Socket = info
+	}
+	s.addResult(result)
+	if config.json {
+		printStreamJSON(s, result)
+	}
+}
+
+// printStreamJSON prints the JSON record of the measurements made on
+// a stream of session s
+func printStreamJSON(s *session, result streamResult) {
+	now := time.Now()
+	printJSON(&jsonReceiverStream{
+		Time:       &now,
+		Session:    s.id,
+		Stream:     result.Stream,
+		Mode:       s.test.Mode.String(),
+		LocalAddr:  result.LocalAddr,
+		RemoteAddr: result.RemoteAddr,
+		Sent:       newJSONTransfer(result.Sent.stats()),
+		Received:   newJSONTransfer(result.Received.stats()),
+		TCPInfo:    result.TCPInfo,
+		Socket:     result.Socket,
+		Datagrams:  result.Datagrams,
+		Error:      result.Error,
+	}, false)
+}
+
+// receiveData reads and discards the data sent over r until the sender
+// closes its side of the connection. Data received during the initial omit
+// period is not accounted for.
+func receiveData(r io.Reader, omit time.Duration, c *counter) (transferStats, error) {
+	stats, err := drain(r, make([]byte, 256*1024), omit, c)
+	if err != nil {
+		errlog.Printf("%s\n", err)
+		return stats, err
+	}
+	errlog.Printf("throughput: %.2f MiB/sec\n", stats.throughput())
+	return stats, nil
+}
+
+// sendData writes data to conn as specified by t
+func sendData(conn net.Conn, t transmission, c *counter) (transferStats, error) {
+	stats, err := transmit(conn, t, c)
+	if err != nil {
+		errlog.Printf("%s\n", err)
+		return stats, err
+	}
+	errlog.Printf("throughput (sending): %.2f MiB/sec\n", stats.throughput())
+	return stats, nil
 }
 
 func receiverUsage(cmd string, f *os.File) {
 	const template = `
 USAGE:
-{{.Tab1}}{{.AppName}} {{.SubCmd}} [-ca <file>] [-cert <file>] [-key <file>]
-{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-addr <network address>]
+{{.Tab1}}{{.AppName}} {{.SubCmd}} [-ca <file>] [-cert <file>] [-key <file>] [-key-type <type>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-require-client-cert]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-addr <network address>] [-interval <duration>] [-4 | -6]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-json] [-sndbuf <size>] [-rcvbuf <size>] [-congestion <name>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-nagle] [-mss <bytes>] [-tos <value> | -dscp <value>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-notsent-lowat <size>] [-iface <name>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-tls-min <version>] [-tls-max <version>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-tls-ciphers <list>] [-tls-curves <list>]
 {{.Tab1}}{{.AppName}} {{.SubCmd}} -help
 
 DESCRIPTION:
 {{.Tab1}}'{{.AppName}} {{.SubCmd}}' starts a receiver which waits for incoming
 {{.Tab1}}network connections from senders, receives and discards data from them.
 {{.Tab1}}It reports on the network thoughput observed while receiving the data.
+{{.Tab1}}When a sender runs in reverse mode, the receiver sends data to it instead.
+{{.Tab1}}In bidirectional mode, it does both simultaneously. At the end of each
+{{.Tab1}}test, the receiver sends its own measurements back to the sender.
 
 OPTIONS:
 {{.Tab1}}-addr <network address>
 {{.Tab2}}specifies the network address this receiver listens to for incoming
-{{.Tab2}}connections. The form of this address is 'interface:port' or
-{{.Tab2}}'tls://interface:port'. Examples of valid adresses are '127.0.0.1:9876'
-{{.Tab2}}'tls://127.0.0.1:9876'.
+{{.Tab2}}connections. The form of this address is 'interface:port',
+{{.Tab2}}'tls://interface:port', 'udp://interface:port', 'http://interface:port',
+{{.Tab2}}'https://interface:port', 'h2://interface:port', 'h2c://interface:port',
+{{.Tab2}}'ws://interface:port', 'wss://interface:port', 'quic://interface:port'
+{{.Tab2}}or 'unix:///path'.
+{{.Tab2}}Examples of valid adresses are '127.0.0.1:9876' 'tls://127.0.0.1:9876'.
 {{.Tab2}}Use a network address starting by 'tls://' to instruct the server to
 {{.Tab2}}use TLS to encrypt the communication channel with senders.
+{{.Tab2}}Use a network address starting by 'udp://' to also receive UDP
+{{.Tab2}}datagrams from senders on the same port. Senders then use a TCP
+{{.Tab2}}connection to that port for controlling the test.
+{{.Tab2}}Use a network address starting by 'http://' or 'https://' to receive
+{{.Tab2}}data from senders as the body of HTTP requests. The certificate and key
+{{.Tab2}}are used for HTTPS as for TLS. Use 'h2://' for also accepting HTTP/2
+{{.Tab2}}over TLS or 'h2c://' for also accepting HTTP/2 without TLS.
+{{.Tab2}}Use a network address starting by 'ws://' or 'wss://' to accept
+{{.Tab2}}WebSocket connections from senders.
+{{.Tab2}}Use a network address starting by 'quic://' to accept QUIC connections
+{{.Tab2}}from senders. The certificate and key are used as for TLS.
+{{.Tab2}}Use a network address starting by 'unix://' to listen to the Unix
+{{.Tab2}}domain socket at the given path, e.g. 'unix:///tmp/netperf.sock'. Of the
+{{.Tab2}}socket options, only the buffer sizes apply to it.
 {{.Tab2}}Default: '{{.DefaultReceiverAddr}}'
 
 {{.Tab1}}-cert <file>
 {{.Tab2}}specifies the path of the PEM-formatted file of the certificate
 {{.Tab2}}this receiver presents to its clients when using TLS connections.
+{{.Tab2}}If neither the default certificate file nor the default key file
+{{.Tab2}}exist, a self-signed certificate is generated and its fingerprint
+{{.Tab2}}is printed, for senders to verify it with '-pin'.
 {{.Tab2}}Default: '{{.DefaultReceiverCert}}'
 
 {{.Tab1}}-key <file>
@@ -166,12 +546,39 @@ OPTIONS:
 {{.Tab2}}clients, when using TLS.
 {{.Tab2}}Default: '{{.DefaultReceiverKey}}'
 
+{{.Tab1}}-key-type <type>
+{{.Tab2}}type of the key of the self-signed certificate generated when the
+{{.Tab2}}default certificate and key files don't exist: 'ecdsa-p256',
+{{.Tab2}}'ecdsa-p384', 'ecdsa-p521', 'ed25519', 'rsa-2048', 'rsa-3072' or
+{{.Tab2}}'rsa-4096'.
+{{.Tab2}}Default: '{{.DefaultKeyType}}'
+
 {{.Tab1}}-ca <file>
 {{.Tab2}}specifies the path of the PEM-formatted file of CA certificates.
 {{.Tab2}}This server accepts client certificates issued by any of those CAs.
-{{.Tab2}}This option is only relevant when using TLS.
-{{.Tab2}}Default: '{{.DefaultReceiverCA}}'
+{{.Tab2}}Senders which present no certificate are also accepted, unless
+{{.Tab2}}'-require-client-cert' is used. This option is only relevant when
+{{.Tab2}}using TLS.
+{{.Tab2}}Default: '{{.DefaultReceiverCA}}', if it exists. Otherwise, the senders
+{{.Tab2}}are not requested a certificate.
+
+{{.Tab1}}-require-client-cert
+{{.Tab2}}reject the senders which don't present a certificate issued by one
+{{.Tab2}}of the CAs specified with '-ca', when using TLS. It requires the CA
+{{.Tab2}}certificates file to exist.
+
+{{.Tab1}}-interval <duration>
+{{.Tab2}}periodically report the throughput observed during the last interval
+{{.Tab2}}on each stream of a test and on all of them, while data is being
+{{.Tab2}}exchanged. Examples of valid values are '1s', '500ms', '10s'.
+{{.Tab2}}Default: no periodic reports
+
+{{.Tab1}}-json
+{{.Tab2}}print to the standard output a JSON record per data connection, in
+{{.Tab2}}a single line, with the measurements made on that connection.
 
+` + socketOptionsUsage + `
+` + tlsOptionsUsage + `
 {{.Tab1}}-help
 {{.Tab2}}print this help
 `
@@ -181,5 +588,6 @@ OPTIONS:
 	tmplFields["DefaultReceiverCert"] = defaultReceiverCert
 	tmplFields["DefaultReceiverKey"] = defaultReceiverKey
 	tmplFields["DefaultReceiverCA"] = defaultReceiverCA
+	tmplFields["DefaultKeyType"] = defaultKeyType
 	render(template, tmplFields, f)
 }
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -17,9 +20,28 @@ type senderConfig struct {
 	// Command line options
 	help       bool
 	addr       string
+	bind       string
+	ca         string
+	pin        string
+	cert       string
+	key        string
+	serverName string
 	duration   time.Duration
+	bytes      string
+	rate       string
+	streamRate bool
 	parallel   int
 	bufferSize string
+	msgSize    string
+	perStream  bool
+	handshakes bool
+	reverse    bool
+	bidir      bool
+	interval   time.Duration
+	omit       time.Duration
+	json       bool
+	socket     socketConfig
+	tls        tlsFlags
 	profile    bool
 }
 
@@ -28,9 +50,28 @@ func senderCmd() command {
 	config := senderConfig{}
 	fset.BoolVar(&config.help, "help", false, "")
 	fset.StringVar(&config.addr, "addr", defaultReceiverAddr, "")
+	fset.StringVar(&config.bind, "bind", "", "")
+	fset.StringVar(&config.ca, "ca", "", "")
+	fset.StringVar(&config.pin, "pin", "", "")
+	fset.StringVar(&config.cert, "cert", "", "")
+	fset.StringVar(&config.key, "key", "", "")
+	fset.StringVar(&config.serverName, "servername", "", "")
 	fset.DurationVar(&config.duration, "duration", defaultDuration, "")
+	fset.StringVar(&config.bytes, "bytes", "", "")
+	fset.StringVar(&config.rate, "rate", "", "")
+	fset.BoolVar(&config.streamRate, "rate-per-stream", false, "")
 	fset.IntVar(&config.parallel, "parallel", defaultParallel, "")
-	fset.StringVar(&config.bufferSize, "len", defaultBufferSize, "")
+	fset.StringVar(&config.bufferSize, "len", "", "")
+	fset.StringVar(&config.msgSize, "msglen", "", "")
+	fset.BoolVar(&config.perStream, "conn-per-stream", false, "")
+	fset.BoolVar(&config.handshakes, "handshakes", false, "")
+	fset.BoolVar(&config.reverse, "reverse", false, "")
+	fset.BoolVar(&config.bidir, "bidir", false, "")
+	fset.DurationVar(&config.interval, "interval", 0, "")
+	fset.DurationVar(&config.omit, "omit", 0, "")
+	fset.BoolVar(&config.json, "json", false, "")
+	config.socket.registerFlags(fset)
+	config.tls.registerFlags(fset)
 	fset.BoolVar(&config.profile, "prof", false, "")
 	run := func(args []string) error {
 		fset.Usage = func() {
@@ -52,21 +93,142 @@ func senderRun(cmdName string, config senderConfig) error {
 		return nil
 	}
 	errlog = setErrlog(cmdName)
+	scheme, _ := splitScheme(config.addr)
+	udp := scheme == "udp"
+	web := isHTTPScheme(scheme)
+	if config.bufferSize == "" {
+		config.bufferSize = defaultBufferSize
+		if udp {
+			config.bufferSize = defaultDatagramSize
+		}
+	}
 	bufsize, err := parseBufferLength(config.bufferSize)
-	if err != nil {
+	if err != nil || bufsize <= 0 || bufsize > maxBufferSize || (udp && (bufsize < datagramHeaderSize || bufsize > maxDatagramSize)) {
 		return fmt.Errorf("invalid buffer size value %q", config.bufferSize)
 	}
+	var msgsize int64
+	if isWebSocketScheme(scheme) {
+		if config.msgSize == "" {
+			config.msgSize = config.bufferSize
+		}
+		msgsize, err = parseBufferLength(config.msgSize)
+		if err != nil || msgsize <= 0 || msgsize > maxBufferSize {
+			return fmt.Errorf("invalid message size value %q", config.msgSize)
+		}
+	} else if config.msgSize != "" {
+		return fmt.Errorf("option -msglen is only supported over WebSocket")
+	}
+	if config.perStream && scheme != "h2" && scheme != "h2c" && scheme != "quic" {
+		return fmt.Errorf("option -conn-per-stream is only supported over HTTP/2 and QUIC")
+	}
+	volume, err := parseBufferLength(config.bytes)
+	if err != nil || volume < 0 {
+		return fmt.Errorf("invalid volume value %q", config.bytes)
+	}
+	if volume == 0 && config.duration <= 0 {
+		return fmt.Errorf("invalid duration value %s", config.duration)
+	}
+	if udp && config.rate == "" {
+		config.rate = defaultUDPRate
+	}
+	rate, err := parseRate(config.rate)
+	if err != nil {
+		return fmt.Errorf("invalid rate value %q", config.rate)
+	}
+	sockopts, err := config.socket.options()
+	if err != nil {
+		return err
+	}
+	local, err := resolveBindAddr(config.bind, sockopts.network("tcp"))
+	if err != nil {
+		return fmt.Errorf("invalid bind value %q: %s", config.bind, err)
+	}
+	if local != nil && scheme == "unix" {
+		return fmt.Errorf("option -bind is not supported over Unix domain sockets")
+	}
+	tlsConfig, err := clientTLSConfig(config.ca, config.pin, config.cert, config.key, config.serverName)
+	if err != nil {
+		return err
+	}
+	if err := config.tls.apply(tlsConfig); err != nil {
+		return err
+	}
+	if isTLSScheme(scheme) && config.ca == "" && config.pin == "" {
+		errlog.Printf("warning: the certificate of the receiver is not verified (use -ca or -pin)\n")
+	}
+	mode := modeSend
+	switch {
+	case config.reverse && config.bidir:
+		return fmt.Errorf("options -reverse and -bidir are mutually exclusive")
+	case config.reverse:
+		mode = modeReverse
+	case config.bidir:
+		mode = modeBidir
+	}
+	if udp && mode != modeSend {
+		return fmt.Errorf("options -reverse and -bidir are not supported over UDP")
+	}
+	if web && mode != modeSend {
+		return fmt.Errorf("options -reverse and -bidir are not supported over HTTP")
+	}
 
 	// Activate profiling
 	if config.profile {
 		defer profile.Start(profile.ProfilePath("./pprof")).Stop()
 	}
+	if config.interval < 0 {
+		return fmt.Errorf("invalid interval value %s", config.interval)
+	}
+	if config.omit < 0 {
+		return fmt.Errorf("invalid omit value %s", config.omit)
+	}
 
-	// Start workers
+	// Open the control connection and register the test with the receiver
 	numWorkers := config.parallel
 	if numWorkers <= 0 {
 		numWorkers = 1
 	}
+	if numWorkers > maxStreams {
+		return fmt.Errorf("invalid parallel value %d (maximum: %d)", config.parallel, maxStreams)
+	}
+	if volume > 0 && volume < int64(numWorkers) {
+		// Every stream must transfer at least one byte
+		return fmt.Errorf("invalid volume value %q: smaller than the number of streams", config.bytes)
+	}
+	if config.handshakes {
+		if volume > 0 || rate > 0 || config.omit > 0 || config.interval > 0 || mode != modeSend {
+			return fmt.Errorf("options -bytes, -rate, -omit, -interval, -reverse and -bidir are not supported with -handshakes")
+		}
+		return handshakeRun(config, local, sockopts, tlsConfig, numWorkers, bufsize, msgsize)
+	}
+	streamRate, targetRate := rate/float64(numWorkers), rate
+	if config.streamRate {
+		streamRate, targetRate = rate, rate*float64(numWorkers)
+	}
+	dial := getDialer(config.addr, local, sockopts, tlsConfig, int(msgsize), config.perStream)
+	protocol := ""
+	switch {
+	case udp:
+		protocol = protocolUDP
+	case web:
+		protocol = protocolHTTP
+	}
+	ctrl, session, err := openControl(dial, &testRequest{
+		Protocol:   protocol,
+		Mode:       mode,
+		Duration:   config.duration,
+		Bytes:      volume,
+		Rate:       streamRate,
+		Omit:       config.omit,
+		BufferSize: bufsize,
+		Streams:    numWorkers,
+	})
+	if err != nil {
+		return err
+	}
+	defer ctrl.Close()
+
+	// Start workers
 	requests := make(chan *workerRequest, numWorkers)
 	var wg sync.WaitGroup
 	wg.Add(numWorkers)
@@ -76,9 +238,25 @@ func senderRun(cmdName string, config senderConfig) error {
 
 	// Establish connections to server, one per worker
 	conns := make([]net.Conn, numWorkers)
-	dial := getDialer(config.addr)
+	var multiplexed *httpClient
+	if (scheme == "h2" || scheme == "h2c") && !config.perStream {
+		// All the streams share a single HTTP/2 connection
+		multiplexed = newHTTPClient(config.addr, local, sockopts, tlsConfig)
+	}
 	for i := 0; i < numWorkers; i++ {
-		conn, err := dial()
+		var conn net.Conn
+		switch {
+		case udp:
+			conn, err = dialDatagrams(config.addr, local, sockopts, session, i)
+		case web:
+			client := multiplexed
+			if client == nil {
+				client = newHTTPClient(config.addr, local, sockopts, tlsConfig)
+			}
+			conn, err = client.send(session, i)
+		default:
+			conn, err = dial()
+		}
 		if err != nil {
 			return err
 		}
@@ -90,13 +268,62 @@ func senderRun(cmdName string, config senderConfig) error {
 	summary := make(chan summaryReport)
 	go collectWorkerResponses(responses, summary)
 
+	// Start periodic reports, if requested
+	var reporter *intervalReporter
+	if config.interval > 0 {
+		// Keep the standard output for the JSON document, if requested
+		logger := outlog
+		if config.json {
+			logger = errlog
+		}
+		reporter = newIntervalReporter(config.interval, logger, mode.senderWrites(), mode.senderReads())
+		reporter.start()
+	}
+
 	// Submit requests to workers
 	buffer := make([]byte, bufsize)
-	for _, conn := range conns {
+	allCounters := make([]*streamCounters, 0, numWorkers)
+	for i, conn := range conns {
+		counters := &streamCounters{id: i, conn: conn}
+		allCounters = append(allCounters, counters)
+		if reporter != nil {
+			reporter.add(counters)
+		}
+	}
+
+	// Track how closely the paced data exchange follows the target rate
+	var tracker *rateTracker
+	if rate > 0 {
+		period := config.interval
+		if period <= 0 {
+			period = time.Second
+		}
+		tracker = newRateTracker(period, config.omit, func() uint64 {
+			total := uint64(0)
+			for _, c := range allCounters {
+				if mode.senderWrites() {
+					total += c.sent.load()
+				} else {
+					total += c.received.load()
+				}
+			}
+			return total
+		})
+		tracker.start()
+	}
+
+	for i, conn := range conns {
 		requests <- &workerRequest{
 			conn:     conn,
+			session:  session,
+			stream:   i,
+			mode:     mode,
 			buffer:   buffer,
 			duration: config.duration,
+			bytes:    streamShare(volume, numWorkers, i),
+			rate:     streamRate,
+			omit:     config.omit,
+			counters: allCounters[i],
 			replyTo:  responses,
 		}
 	}
@@ -105,20 +332,45 @@ func senderRun(cmdName string, config senderConfig) error {
 	// Wait for workers to finish their execution
 	wg.Wait()
 	close(responses)
+	if reporter != nil {
+		reporter.stop()
+	}
+	if tracker != nil {
+		tracker.stop()
+	}
 
 	// Close network connections
 	for _, conn := range conns {
 		conn.Close()
 	}
 
-	// Collect and print summary report
+	// Collect the receiver's measurements and print summary report
 	report := <-summary
-	if report.dataVolume > 0.0 {
-		outlog.Printf("duration:                       %s\n", report.duration)
-		outlog.Printf("streams:                        %d\n", report.numWorkers)
-		outlog.Printf("data volume:                    %.2f MiB\n", report.dataVolume)
-		outlog.Printf("aggregated throughput:          %.2f MiB/sec\n", report.aggregateThroughput)
-		outlog.Printf("avg/std throughput per stream:  %.2f / %.2f MiB/sec\n", report.avgStreamThroughput, report.stdStreamThroughput)
+	report.omit = config.omit
+	if tracker != nil {
+		paced := report.received
+		if mode.senderWrites() {
+			paced = report.sent
+		}
+		report.pacing = tracker.report(targetRate, paced.aggregateThroughput*float64(MB)*8)
+	}
+	results, err := fetchResults(ctrl)
+	if err != nil {
+		errlog.Printf("could not get results from receiver: %s\n", err)
+	} else {
+		report.receiver = summarizeResults(results)
+		if udp {
+			report.summarizeDatagrams()
+		}
+	}
+	if config.json {
+		if err := printJSON(newJSONSenderReport(config, mode, bufsize, msgsize, volume, report, results), true); err != nil {
+			return err
+		}
+	} else if report.sent.dataVolume > 0.0 || report.received.dataVolume > 0.0 {
+		printSummary(mode, volume, report)
+	} else if config.omit > 0 && len(report.errors) == 0 {
+		errlog.Printf("no data measured: the exchange finished within the omitted period\n")
 	}
 	if len(report.errors) > 0 {
 		return report.errors[0]
@@ -126,159 +378,805 @@ func senderRun(cmdName string, config senderConfig) error {
 	return nil
 }
 
+// openControl establishes the control connection with the receiver and
+// requests it to set up the test. It returns the connection and the
+// session identifier assigned by the receiver to the test.
+func openControl(dial func() (net.Conn, error), test *testRequest) (net.Conn, string, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, "", err
+	}
+	conn.SetDeadline(time.Now().Add(readGracePeriod))
+	var accept testAccept
+	if err = writeMessage(conn, &connHeader{Test: test}); err == nil {
+		err = readMessage(conn, &accept)
+	}
+	if err == nil && accept.Error != "" {
+		err = fmt.Errorf("test rejected by receiver: %s", accept.Error)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, "", err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, accept.Session, nil
+}
+
+// fetchResults notifies the receiver over the control connection that
+// the sender is done and returns the measurements made by the receiver
+func fetchResults(ctrl net.Conn) (*testResults, error) {
+	ctrl.SetDeadline(time.Now().Add(2 * readGracePeriod))
+	if err := writeMessage(ctrl, &testDone{}); err != nil {
+		return nil, err
+	}
+	var results testResults
+	if err := readMessage(ctrl, &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
 type workerRequest struct {
 	conn     net.Conn
+	session  string
+	stream   int
+	mode     transferMode
 	duration time.Duration
+	bytes    int64         // if not zero, overrides duration
+	rate     float64       // bits/sec, zero for unlimited
+	omit     time.Duration // initial period excluded from measurements
 	buffer   []byte
+	counters *streamCounters
 	replyTo  chan *workerResponse
 }
 
 type workerResponse struct {
-	req        *workerRequest
-	err        error
-	start      time.Time
-	end        time.Time
-	dataVolume float64 // MiB
-	throughput float64 // MiB/sec
+	req      *workerRequest
+	err      error
+	start    time.Time
+	end      time.Time
+	sent     transferStats
+	received transferStats
+	tcpInfo  *tcpInfo    // nil if not available
+	socket   *socketInfo // nil if not available
+	tls      *tlsInfo    // nil if not a TLS connection
+
+	datagrams uint64 // number of datagrams sent, only for UDP tests
 }
 
 func worker(workerID int, wg *sync.WaitGroup, requests <-chan *workerRequest) {
 	defer wg.Done()
+	buffer := make([]byte, 256*1024)
 	for req := range requests {
-		sent := float64(0)
-		resp := &workerResponse{
-			req:   req,
-			start: time.Now(),
-		}
-		timeout := time.After(req.duration)
-	loop:
-		for {
-			select {
-			case <-timeout:
-				// Stop sending data
-				break loop
-
-			default:
-				n, err := req.conn.Write(req.buffer)
-				sent += float64(n)
-				if err != nil {
-					resp.err = err
-					break loop
-				}
+		req.replyTo <- exchange(req, buffer)
+	}
+}
+
+// exchange sends the stream header to the receiver and then performs the
+// data exchange requested by req. readBuffer is used for reading the data
+// sent by the receiver, if any.
+func exchange(req *workerRequest, readBuffer []byte) *workerResponse {
+	resp := &workerResponse{
+		req: req,
+	}
+	datagrams, udp := req.conn.(*datagramConn)
+	if _, web := req.conn.(*httpStreamConn); !udp && !web {
+		// Datagrams and HTTP requests identify their stream themselves
+		if resp.err = writeHeader(req); resp.err != nil {
+			return resp
+		}
+	}
+	resp.start = time.Now()
+	var wg sync.WaitGroup
+	var sendErr, recvErr error
+	if req.mode.senderReads() {
+		var r io.Reader = req.conn
+		if req.bytes == 0 {
+			// Don't wait forever if the receiver does not close its side of
+			// the connection when the requested duration is over
+			req.conn.SetReadDeadline(resp.start.Add(req.omit + req.duration + readGracePeriod))
+		} else {
+			// The time the transfer takes is unknown: give up once the
+			// receiver stalls instead
+			timeout := readGracePeriod
+			if req.rate > 0 {
+				// The receiver may pause between its writes to follow the rate
+				timeout += time.Duration(float64(len(req.buffer)*8) / req.rate * float64(time.Second))
 			}
+			r = &idleReader{conn: req.conn, timeout: timeout}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp.received, recvErr = drain(r, readBuffer, req.omit, &req.counters.received)
+		}()
+	}
+	if req.mode.senderWrites() {
+		resp.sent, sendErr = transmit(req.conn, transmission{
+			buffer:   req.buffer,
+			duration: req.duration,
+			volume:   req.bytes,
+			rate:     req.rate,
+			omit:     req.omit,
+		}, &req.counters.sent)
+		if udp {
+			// The sender lets the receiver know over the control connection
+			resp.datagrams = datagrams.sent
+		} else if sendErr == nil {
+			// Let the receiver know we are done sending
+			sendErr = closeWrite(req.conn)
 		}
-		if resp.err == nil {
-			resp.end = time.Now()
-			resp.dataVolume = sent / float64(MB)
-			resp.throughput = resp.dataVolume / resp.end.Sub(resp.start).Seconds()
+	}
+	wg.Wait()
+	resp.end = time.Now()
+	if req.omit > 0 {
+		// The exchange is measured from the end of the omit period
+		resp.start = resp.start.Add(req.omit)
+		if resp.start.After(resp.end) {
+			resp.start = resp.end
 		}
-		req.replyTo <- resp
 	}
+	if sendErr != nil {
+		resp.err = sendErr
+	} else {
+		resp.err = recvErr
+	}
+	if info, err := getTCPInfo(req.conn); err == nil {
+		resp.tcpInfo = info
+	}
+	if info, err := getSocketInfo(req.conn); err == nil {
+		resp.socket = info
+	}
+	resp.tls = getTLSInfo(req.conn)
+	return resp
+}
+
+// writeHeader sends to the receiver the header which attaches the
+// connection of req to its test session
+func writeHeader(req *workerRequest) error {
+	return writeMessage(req.conn, &connHeader{
+		Stream: &streamHeader{
+			Session: req.session,
+			Stream:  req.stream,
+		},
+	})
 }
 
 type summaryReport struct {
-	numWorkers          int
+	numWorkers int
+	numConns   int // connections the streams are sent over, fewer with HTTP/2
+	sent       directionSummary
+	received   directionSummary
+	receiver   *peerSummary  // measurements reported by the receiver, if any
+	pacing     *pacingReport // only for paced exchanges
+	omit       time.Duration // initial period excluded from measurements
+	duration   time.Duration
+	errors     []error
+	responses  []*workerResponse
+
+	// only for UDP tests whose receiver reported its measurements
+	datagrams     map[int]datagramSummary // per stream
+	datagramTotal *datagramSummary
+}
+
+// directionSummary holds the aggregated measurements of the data
+// transferred in one direction by all the streams
+type directionSummary struct {
 	dataVolume          float64 // MiB
 	aggregateThroughput float64 // MiB/sec
 	avgStreamThroughput float64 // MiB/sec
 	stdStreamThroughput float64 // MiB/sec
-	duration            time.Duration
-	errors              []error
+}
+
+// peerSummary holds the aggregated measurements made by one of the ends
+type peerSummary struct {
+	sent      directionSummary
+	received  directionSummary
+	tcpInfo   map[int]*tcpInfo       // per stream, if available
+	socket    map[int]*socketInfo    // per stream, if available
+	addrs     map[int]string         // local and remote addresses per stream
+	datagrams map[int]*datagramStats // per stream, only for UDP tests
 }
 
 func collectWorkerResponses(responses <-chan *workerResponse, summary chan<- summaryReport) {
-	dataVolume := float64(0)
 	numWorkers := 0
 	start := time.Now().Add(3000 * time.Hour)
 	end := time.Now().Add(-3000 * time.Hour)
-	throughputs := make([]float64, 0, 128)
+	sent := make([]transferStats, 0, 128)
+	received := make([]transferStats, 0, 128)
 	errors := make([]error, 0, 128)
+	all := make([]*workerResponse, 0, 128)
+	conns := make(map[net.Conn]bool)
 	for resp := range responses {
 		numWorkers += 1
+		all = append(all, resp)
+		conns[baseConn(resp.req.conn)] = true
+		if resp.err != nil {
+			errors = append(errors, resp.err)
+			continue
+		}
 		if resp.start.Before(start) {
 			start = resp.start
 		}
 		if resp.end.After(end) {
 			end = resp.end
 		}
+		sent = append(sent, resp.sent)
+		received = append(received, resp.received)
+	}
+	summary <- summaryReport{
+		numWorkers: numWorkers,
+		numConns:   len(conns),
+		sent:       summarize(sent),
+		received:   summarize(received),
+		duration:   end.Sub(start),
+		errors:     errors,
+		responses:  all,
+	}
+}
+
+// summarizeResults aggregates the measurements reported by the receiver
+func summarizeResults(results *testResults) *peerSummary {
+	sent := make([]transferStats, 0, len(results.Streams))
+	received := make([]transferStats, 0, len(results.Streams))
+	tcpInfo := make(map[int]*tcpInfo)
+	socket := make(map[int]*socketInfo)
+	addrs := make(map[int]string)
+	datagrams := make(map[int]*datagramStats)
+	for _, r := range results.Streams {
+		if r.Datagrams != nil {
+			datagrams[r.Stream] = r.Datagrams
+		}
+		if r.LocalAddr != "" && r.RemoteAddr != "" {
+			addrs[r.Stream] = formatAddrs(r.LocalAddr, r.RemoteAddr)
+		}
+		if r.TCPInfo != nil {
+			tcpInfo[r.Stream] = r.TCPInfo
+		}
+		if r.Socket != nil {
+			socket[r.Stream] = r.Socket
+		}
+		if r.Error != "" {
+			continue
+		}
+		sent = append(sent, r.Sent.stats())
+		received = append(received, r.Received.stats())
+	}
+	return &peerSummary{
+		sent:      summarize(sent),
+		received:  summarize(received),
+		tcpInfo:   tcpInfo,
+		socket:    socket,
+		addrs:     addrs,
+		datagrams: datagrams,
+	}
+}
+
+// summarizeDatagrams combines the number of datagrams sent on each stream of
+// a UDP test with the measurements made by the receiver
+func (r *summaryReport) summarizeDatagrams() {
+	r.datagrams = make(map[int]datagramSummary)
+	streams := make([]datagramSummary, 0, len(r.responses))
+	for _, resp := range r.responses {
+		d := newDatagramSummary(resp.datagrams, r.receiver.datagrams[resp.req.stream])
+		r.datagrams[resp.req.stream] = d
+		streams = append(streams, d)
+	}
+	total := sumDatagrams(streams)
+	r.datagramTotal = &total
+}
+
+// formatAddrs formats the local and remote addresses of a connection,
+// along with the IP version in use or whether it is a Unix domain socket
+func formatAddrs(local, remote string) string {
+	family := "Unix"
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		family = "IPv6"
+		if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+			family = "IPv4"
+		}
+	}
+	return fmt.Sprintf("%s -> %s (%s)", local, remote, family)
+}
+
+// summarize aggregates the measurements of a set of one-way transfers
+// performed simultaneously
+func summarize(transfers []transferStats) directionSummary {
+	if len(transfers) == 0 {
+		return directionSummary{}
+	}
+	dataVolume := float64(0)
+	start, end := transfers[0].start, transfers[0].end
+	throughputs := make([]float64, 0, len(transfers))
+	for _, t := range transfers {
+		dataVolume += t.dataVolume()
+		throughputs = append(throughputs, t.throughput())
+		if t.start.Before(start) {
+			start = t.start
+		}
+		if t.end.After(end) {
+			end = t.end
+		}
+	}
+	d := directionSummary{
+		dataVolume: dataVolume,
+	}
+	if elapsed := end.Sub(start).Seconds(); elapsed > 0 {
+		d.aggregateThroughput = dataVolume / elapsed
+	}
+	_, d.avgStreamThroughput, d.stdStreamThroughput = stats(throughputs)
+	return d
+}
+
+// printSummary prints the summary report of a data exchange performed
+// in the given mode. volume is the requested amount of data to transfer
+// in each direction or zero if the exchange was limited by duration.
+func printSummary(mode transferMode, volume int64, report summaryReport) {
+	lines := [][2]string{
+		{"mode:", mode.String()},
+	}
+	if volume == 0 {
+		lines = append(lines, [2]string{"duration:", report.duration.String()})
+	} else {
+		minTime, maxTime := completionTimes(report.responses)
+		lines = append(lines,
+			[2]string{"requested volume:", fmt.Sprintf("%.2f MiB", float64(volume)/float64(MB))},
+			[2]string{"completion time:", report.duration.String()},
+			[2]string{"min/max completion time per stream:", fmt.Sprintf("%s / %s", minTime, maxTime)},
+		)
+	}
+	if report.omit > 0 {
+		lines = append(lines, [2]string{"omitted warm-up period:", report.omit.String()})
+	}
+	lines = append(lines, [2]string{"streams:", fmt.Sprintf("%d", report.numWorkers)})
+	multiplexed := report.numConns < report.numWorkers
+	if multiplexed {
+		lines = append(lines, [2]string{"connections:", fmt.Sprintf("%d", report.numConns)})
+	}
+	addDirection := func(prefix string, d directionSummary) {
+		lines = append(lines,
+			[2]string{prefix + "data volume:", fmt.Sprintf("%.2f MiB", d.dataVolume)},
+			[2]string{prefix + "aggregated throughput:", fmt.Sprintf("%.2f MiB/sec", d.aggregateThroughput)},
+			[2]string{prefix + "avg/std throughput per stream:", fmt.Sprintf("%.2f / %.2f MiB/sec", d.avgStreamThroughput, d.stdStreamThroughput)},
+		)
+	}
+	switch mode {
+	case modeSend:
+		addDirection("", report.sent)
+		if report.receiver != nil {
+			addDirection("receiver ", report.receiver.received)
+		}
+	case modeReverse:
+		addDirection("", report.received)
+		if report.receiver != nil {
+			addDirection("receiver ", report.receiver.sent)
+		}
+	case modeBidir:
+		addDirection("upload ", report.sent)
+		addDirection("download ", report.received)
+		if report.receiver != nil {
+			addDirection("receiver upload ", report.receiver.received)
+			addDirection("receiver download ", report.receiver.sent)
+		}
+	}
+	for _, resp := range sortedResponses(report.responses) {
+		stream := resp.req.stream
+		lines = append(lines, [2]string{fmt.Sprintf("stream %d addresses:", stream),
+			formatAddrs(resp.req.conn.LocalAddr().String(), resp.req.conn.RemoteAddr().String())})
+		if multiplexed && resp.err == nil {
+			// The streams compete for the same connection
+			lines = append(lines, [2]string{fmt.Sprintf("stream %d throughput:", stream), streamThroughput(mode, resp)})
+		}
+		if resp.socket != nil {
+			lines = append(lines, [2]string{fmt.Sprintf("stream %d socket:", stream), resp.socket.String()})
+		}
+		if resp.tcpInfo != nil {
+			lines = append(lines, [2]string{fmt.Sprintf("stream %d tcp info:", stream), resp.tcpInfo.String()})
+		}
+		if resp.tls != nil {
+			lines = append(lines, [2]string{fmt.Sprintf("stream %d tls:", stream), resp.tls.String()})
+		}
+		if d, ok := report.datagrams[stream]; ok {
+			lines = append(lines, [2]string{fmt.Sprintf("stream %d datagrams:", stream), d.String()})
+		}
+		if report.receiver == nil {
+			continue
+		}
+		if addrs, ok := report.receiver.addrs[stream]; ok {
+			lines = append(lines, [2]string{fmt.Sprintf("receiver stream %d addresses:", stream), addrs})
+		}
+		if info := report.receiver.socket[stream]; info != nil {
+			lines = append(lines, [2]string{fmt.Sprintf("receiver stream %d socket:", stream), info.String()})
+		}
+		if info := report.receiver.tcpInfo[stream]; info != nil {
+			lines = append(lines, [2]string{fmt.Sprintf("receiver stream %d tcp info:", stream), info.String()})
+		}
+	}
+	if d := report.datagramTotal; d != nil {
+		lines = append(lines,
+			[2]string{"datagrams sent/received:", fmt.Sprintf("%d / %d", d.sent, d.received)},
+			[2]string{"datagrams lost:", fmt.Sprintf("%d (%.2f%%)", d.lost, 100*d.lossRatio())},
+			[2]string{"datagrams out of order:", fmt.Sprintf("%d", d.outOfOrder)},
+			[2]string{"avg jitter per stream:", fmt.Sprintf("%.3f ms", d.jitter)},
+		)
+	}
+	if p := report.pacing; p != nil {
+		lines = append(lines,
+			[2]string{"target rate:", formatRate(p.target)},
+			[2]string{"achieved rate:", fmt.Sprintf("%s (%.1f%% of target)", formatRate(p.achieved), 100*p.achieved/p.target)},
+		)
+		if p.samples > 0 {
+			lines = append(lines,
+				[2]string{fmt.Sprintf("min/max rate per %s:", p.period), fmt.Sprintf("%s / %s", formatRate(p.minRate), formatRate(p.maxRate))},
+				[2]string{"std deviation from target:", formatRate(p.stdRate)},
+				[2]string{"periods on target:", fmt.Sprintf("%d/%d (within %.0f%%)", p.onTarget, p.samples, 100*p.tolerance)},
+			)
+		}
+	}
+	printLines(lines)
+}
+
+// formatRate formats a rate expressed in bits/sec
+func formatRate(bps float64) string {
+	return fmt.Sprintf("%.2f Mbit/sec", bps/1e6)
+}
+
+// streamThroughput returns the throughput observed by the worker of a
+// stream in the direction(s) of mode
+func streamThroughput(mode transferMode, resp *workerResponse) string {
+	switch mode {
+	case modeSend:
+		return fmt.Sprintf("%.2f MiB/sec", resp.sent.throughput())
+	case modeReverse:
+		return fmt.Sprintf("%.2f MiB/sec", resp.received.throughput())
+	}
+	return fmt.Sprintf("upload %.2f / download %.2f MiB/sec", resp.sent.throughput(), resp.received.throughput())
+}
+
+// sortedResponses returns a copy of responses sorted by stream
+func sortedResponses(responses []*workerResponse) []*workerResponse {
+	sorted := append([]*workerResponse(nil), responses...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].req.stream < sorted[j].req.stream
+	})
+	return sorted
+}
+
+// completionTimes returns the shortest and longest time taken by the
+// successful workers to complete their data exchange
+func completionTimes(responses []*workerResponse) (min, max time.Duration) {
+	first := true
+	for _, resp := range responses {
 		if resp.err != nil {
-			errors = append(errors, resp.err)
 			continue
 		}
-		dataVolume += resp.dataVolume
-		throughputs = append(throughputs, resp.throughput)
+		elapsed := resp.end.Sub(resp.start)
+		if first || elapsed < min {
+			min = elapsed
+		}
+		if first || elapsed > max {
+			max = elapsed
+		}
+		first = false
 	}
-	_, avg, std := stats(throughputs)
-	summary <- summaryReport{
-		numWorkers:          numWorkers,
-		dataVolume:          dataVolume,
-		aggregateThroughput: dataVolume / end.Sub(start).Seconds(),
-		avgStreamThroughput: avg,
-		stdStreamThroughput: std,
-		duration:            end.Sub(start),
-		errors:              errors,
+	return min, max
+}
+
+// printLines prints a list of key-value pairs to the output log, aligning
+// the values in a single column
+func printLines(lines [][2]string) {
+	width := 0
+	for _, l := range lines {
+		width = maxInt(width, len(l[0]))
+	}
+	for _, l := range lines {
+		outlog.Printf("%-*s  %s\n", width, l[0], l[1])
+	}
+}
+
+// resolveBindAddr resolves the local address the connections of the sender
+// are bound to. bind is a host name or IP address: the local ports are
+// chosen by the kernel since the sender establishes several connections.
+// network restricts the IP version of the address. It returns nil if bind
+// is empty.
+func resolveBindAddr(bind, network string) (*net.TCPAddr, error) {
+	if bind == "" {
+		return nil, nil
 	}
+	return net.ResolveTCPAddr(network, net.JoinHostPort(bind, "0"))
 }
 
 // getDialer returns a function to dial to the server
 // according to the format of the addr argument.
-// addr can be of the form: 'host:port' or 'tls://host:port'.
-func getDialer(addr string) func() (net.Conn, error) {
-	const prefix = "tls://"
+// addr can be of the form: 'host:port', 'tls://host:port',
+// 'udp://host:port', 'http://host:port', 'https://host:port',
+// 'h2://host:port', 'h2c://host:port', 'ws://host:port', 'wss://host:port'
+// 'quic://host:port' or 'unix:///path'. For HTTP addresses, the function establishes the
+// control connection of the test. For WebSocket addresses, the messages
+// sent are of up to msgSize bytes. For QUIC addresses, the function opens
+// a stream of a shared connection or, if connPerStream is set, of its own
+// connection. If local is not nil, connections are established from that
+// address. Secure connections use the TLS configuration tlsConfig.
+// The socket options are applied to every connection, before it is
+// established when possible.
+func getDialer(addr string, local *net.TCPAddr, opts *socketOptions, tlsConfig *tls.Config, msgSize int, connPerStream bool) func() (net.Conn, error) {
+	url := addr
+	scheme, addr := splitScheme(addr)
+	d := newDialer(local, opts)
+	network := opts.network("tcp")
+	config := tlsConfig.Clone()
+	switch scheme {
+	case "tls":
+		return func() (net.Conn, error) {
+			return dialTLS(context.Background(), d, opts, network, addr, config)
+		}
+	case "http", "h2c":
+		return func() (net.Conn, error) {
+			conn, err := applyOptions(opts)(d.Dial(network, addr))
+			if err != nil {
+				return nil, err
+			}
+			if err := upgradeControl(conn, "http", addr); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		}
+	case "https", "h2":
+		config.NextProtos = []string{"http/1.1"}
+		return func() (net.Conn, error) {
+			conn, err := dialTLS(context.Background(), d, opts, network, addr, config)
+			if err != nil {
+				return nil, err
+			}
+			if err := upgradeControl(conn, "https", addr); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		}
+	case "ws", "wss":
+		return func() (net.Conn, error) {
+			return dialWebSocket(url, d, opts, config, msgSize)
+		}
+	case "quic":
+		return newQUICClient(addr, local, opts, config, connPerStream).open
+	case "unix":
+		return func() (net.Conn, error) {
+			return d.Dial("unix", addr)
+		}
+	}
+	return func() (net.Conn, error) {
+		return applyOptions(opts)(d.Dial(network, addr))
+	}
+}
+
+// newDialer returns a dialer for establishing connections from local, if
+// not nil, and with the socket options applied before connecting
+func newDialer(local *net.TCPAddr, opts *socketOptions) *net.Dialer {
 	d := &net.Dialer{
 		Timeout: 5 * time.Second,
+		Control: opts.control,
 	}
-	if !strings.HasPrefix(addr, prefix) {
-		return func() (net.Conn, error) {
-			return d.Dial("tcp", addr)
+	if local != nil {
+		d.LocalAddr = local
+	}
+	return d
+}
+
+// applyOptions returns a function which applies the socket options which
+// cannot be set before connecting to the connection returned by a dial
+// function, if successful
+func applyOptions(opts *socketOptions) func(net.Conn, error) (net.Conn, error) {
+	return func(conn net.Conn, err error) (net.Conn, error) {
+		if err != nil {
+			return nil, err
+		}
+		if err := opts.apply(conn); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("cannot set socket options: %s", err)
 		}
+		return conn, nil
 	}
-	addr = strings.TrimPrefix(addr, prefix)
-	config := tls.Config{
-		InsecureSkipVerify: true,
+}
+
+// dialDatagrams returns the connection for sending the datagrams of the
+// given stream of a UDP test to addr, of the form 'udp://host:port'
+func dialDatagrams(addr string, local *net.TCPAddr, opts *socketOptions, session string, stream int) (net.Conn, error) {
+	_, addr = splitScheme(addr)
+	d := newDialer(local, opts)
+	if local != nil {
+		d.LocalAddr = &net.UDPAddr{IP: local.IP, Zone: local.Zone}
 	}
-	return func() (net.Conn, error) {
-		return tls.DialWithDialer(d, "tcp", addr, &config)
+	conn, err := d.Dial(opts.network("udp"), addr)
+	if err != nil {
+		return nil, err
+	}
+	dc, err := newDatagramConn(conn, session, stream)
+	if err != nil {
+		conn.Close()
+		return nil, err
 	}
+	return dc, nil
 }
 
 func senderUsage(cmd string, f *os.File) {
 	const template = `
 USAGE:
-{{.Tab1}}{{.AppName}} {{.SubCmd}} [-duration <duration>] [-len <buffer length>]
+{{.Tab1}}{{.AppName}} {{.SubCmd}} [-duration <duration> | -bytes <size>] [-len <buffer length>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-msglen <message length>] [-conn-per-stream]
 {{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-parallel <integer>] [-addr <network address>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-4 | -6] [-bind <address>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-ca <file>] [-pin <fingerprint>] [-cert <file> -key <file>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-servername <name>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-tls-min <version>] [-tls-max <version>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-tls-ciphers <list>] [-tls-curves <list>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-reverse | -bidir | -handshakes] [-interval <duration>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-omit <duration>] [-rate <bits/sec> [-rate-per-stream]] [-json]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-sndbuf <size>] [-rcvbuf <size>] [-congestion <name>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-nagle] [-mss <bytes>] [-tos <value> | -dscp <value>]
+{{.Tab1}}{{.AppNameFiller}} {{.SubCmdFiller}} [-notsent-lowat <size>] [-iface <name>]
 {{.Tab1}}{{.AppName}} {{.SubCmd}} -help
 
 DESCRIPTION:
 {{.Tab1}}'{{.AppName}} {{.SubCmd}}' establishes a network connection with the receiver
 {{.Tab1}}for sending data to it. It reports the observed network throughput
-{{.Tab1}}of that exchange.
+{{.Tab1}}of that exchange, as measured by both the sender and the receiver.
 {{.Tab1}}For this command to work, a receiver must be already running. To start a
 {{.Tab1}}receiver use the command '{{.AppName}} {{.ReceiveSubCmd}}'
 
 OPTIONS:
 {{.Tab1}}-addr <network address>
 {{.Tab2}}network address of the receiver. The form of the address is 'host:port'
-{{.Tab2}}if the receiver expects a TCP connection, or 'tls://host:port'
-{{.Tab2}}if the receiver expects a TLS connection.
+{{.Tab2}}if the receiver expects a TCP connection, 'tls://host:port'
+{{.Tab2}}if the receiver expects a TLS connection, or 'udp://host:port' for
+{{.Tab2}}sending UDP datagrams. In the latter case, the datagrams lost, the
+{{.Tab2}}datagrams received out of order and the jitter are also reported.
+{{.Tab2}}Use 'http://host:port' or 'https://host:port' for sending the data
+{{.Tab2}}of each stream as the body of an HTTP POST request, each over its own
+{{.Tab2}}connection. Use 'h2://host:port' for HTTP/2 over TLS or 'h2c://host:port'
+{{.Tab2}}for HTTP/2 without TLS: the requests of all the streams are then sent
+{{.Tab2}}concurrently over a single connection, and the throughput of each
+{{.Tab2}}stream is also reported. Use 'ws://host:port' or 'wss://host:port' for
+{{.Tab2}}establishing WebSocket connections, over which data is sent as binary
+{{.Tab2}}messages. Use 'quic://host:port' for opening a QUIC stream per stream,
+{{.Tab2}}all over the same QUIC connection unless '-conn-per-stream' is used.
+{{.Tab2}}Use 'unix:///path' for connecting to the Unix domain socket at /path.
+{{.Tab2}}Of the socket options, only the buffer sizes apply to it.
+{{.Tab2}}UDP and HTTP cannot be used in combination with '-reverse' or '-bidir'.
 {{.Tab2}}Default: '{{.DefaultReceiverAddr}}'
 
+{{.Tab1}}-bidir
+{{.Tab2}}send data to the receiver and receive data from it simultaneously over
+{{.Tab2}}each connection, for the specified duration. The throughput observed in
+{{.Tab2}}each direction is reported separately.
+{{.Tab2}}This option cannot be used in combination with '-reverse'.
+
+{{.Tab1}}-bind <address>
+{{.Tab2}}local IP address or host name the connections are established from,
+{{.Tab2}}e.g. '192.0.2.1', which selects the network interface the traffic
+{{.Tab2}}leaves through on multi-homed hosts. The interface actually used by
+{{.Tab2}}each connection is reported.
+{{.Tab2}}Default: chosen by the kernel
+
+{{.Tab1}}-bytes <size>
+{{.Tab2}}amount of data to transfer in each direction, instead of transferring
+{{.Tab2}}data for a fixed duration. The volume is evenly distributed among
+{{.Tab2}}the streams and the time taken to complete the transfer is reported.
+{{.Tab2}}Examples of valid values for this option are: '512MB', '10G', '1TB'.
+{{.Tab2}}The suffixes 'K', 'M', 'G' and 'T' are understood as powers of 1024.
+{{.Tab2}}When this option is used, '-duration' is ignored.
+
+{{.Tab1}}-ca <file>
+{{.Tab2}}path of the PEM-formatted file of the CA certificates the certificate
+{{.Tab2}}of the receiver is verified against, when using TLS, HTTPS, HTTP/2,
+{{.Tab2}}secure WebSocket or QUIC.
+{{.Tab2}}Default: the certificate of the receiver is not verified, unless
+{{.Tab2}}'-pin' is used, and a warning is printed
+
+{{.Tab1}}-cert <file>
+{{.Tab2}}path of the PEM-formatted file of the certificate this sender presents
+{{.Tab2}}to the receiver, for authenticating with mutual TLS. It must be used
+{{.Tab2}}in combination with '-key'.
+{{.Tab2}}Default: no certificate is presented
+
+{{.Tab1}}-conn-per-stream
+{{.Tab2}}establish a connection per stream with HTTP/2 and QUIC, instead of
+{{.Tab2}}multiplexing all the streams over a single connection. Only supported
+{{.Tab2}}over HTTP/2 and QUIC.
+
 {{.Tab1}}-duration <duration>
 {{.Tab2}}amount of time for sending data. Examples of valid values
 {{.Tab2}}for this option are '60s', '1h30m', '120s', '2h', etc.
 {{.Tab2}}Default: '{{.DefaultDuration}}'
 
+{{.Tab1}}-handshakes
+{{.Tab2}}measure the rate of TLS handshakes the receiver completes instead of
+{{.Tab2}}the throughput. Each stream repeatedly establishes a connection, sends
+{{.Tab2}}the header of the connection and waits for the receiver to close it.
+{{.Tab2}}The test runs twice for the duration specified by '-duration': first
+{{.Tab2}}without and then with TLS session resumption. The number of handshakes
+{{.Tab2}}per second and the distribution of their latencies are reported for
+{{.Tab2}}each run. Only supported over TLS, HTTPS, HTTP/2 and secure WebSocket,
+{{.Tab2}}and not in combination with '-bytes', '-rate', '-omit', '-interval',
+{{.Tab2}}'-reverse' or '-bidir'.
+
+{{.Tab1}}-interval <duration>
+{{.Tab2}}periodically report the throughput observed during the last interval
+{{.Tab2}}on each stream and on all streams, while data is being exchanged.
+{{.Tab2}}Examples of valid values for this option are '1s', '500ms', '10s'.
+{{.Tab2}}Default: no periodic reports
+
+{{.Tab1}}-json
+{{.Tab2}}print the report as a single JSON document which includes the test
+{{.Tab2}}configuration, the summary and the measurements made on every stream
+{{.Tab2}}by both the sender and the receiver. When used in combination with
+{{.Tab2}}'-interval', the periodic reports are printed to the standard error.
+
+{{.Tab1}}-key <file>
+{{.Tab2}}path of the PEM-formatted file of the private key of the certificate
+{{.Tab2}}specified with '-cert'.
+
 {{.Tab1}}-len <buffer length>
 {{.Tab2}}size in bytes of the buffer used for sending data to the receiver.
 {{.Tab2}}Examples of valid values for this option are: '4096', '128K', '512KB',
 {{.Tab2}}'1MB'. The suffix 'K' is understood to be 1024 and the suffix 'M' to be
-{{.Tab2}}1024x1024.
-{{.Tab2}}Default: '{{.DefaultBufferSize}}'
+{{.Tab2}}1024x1024. With UDP, this is the size of every datagram, including a
+{{.Tab2}}header of {{.DatagramHeaderSize}} bytes, up to {{.MaxDatagramSize}} bytes.
+{{.Tab2}}Default: '{{.DefaultBufferSize}}', or '{{.DefaultDatagramSize}}' with UDP
+
+{{.Tab1}}-msglen <message length>
+{{.Tab2}}maximum size in bytes of the binary messages sent over WebSocket
+{{.Tab2}}connections, by both the sender and the receiver. The data of each
+{{.Tab2}}write of a buffer is split into messages of this size. The same
+{{.Tab2}}suffixes as for -len are accepted. Only supported over WebSocket.
+{{.Tab2}}Default: the buffer length
+
+{{.Tab1}}-omit <duration>
+{{.Tab2}}length of the initial period of each data exchange which is excluded
+{{.Tab2}}from the measurements reported by both the sender and the receiver,
+{{.Tab2}}for instance to skip TCP slow start. Data is still transferred during
+{{.Tab2}}that period, which is added to the duration specified by '-duration'.
+{{.Tab2}}Default: no warm-up period
 
 {{.Tab1}}-parallel <integer>
-{{.Tab2}}number of simultaneous network connections to establish with the receiver.
+{{.Tab2}}number of simultaneous network connections to establish with the receiver,
+{{.Tab2}}up to {{.MaxStreams}}.
 {{.Tab2}}Default: {{.DefaultParallel}}
 
+{{.Tab1}}-pin <fingerprint>
+{{.Tab2}}SHA-256 fingerprint the certificate of the receiver must have, such as
+{{.Tab2}}the one a receiver prints when it generates a self-signed certificate,
+{{.Tab2}}in hexadecimal with or without colons. Unless '-ca' is also used, the
+{{.Tab2}}certificate is only verified against this fingerprint.
+{{.Tab2}}Default: the fingerprint of the certificate is not verified
+
+{{.Tab1}}-rate <bits/sec>
+{{.Tab2}}pace the data exchange so that data is sent at the specified average
+{{.Tab2}}rate, in bits per second. By default, this is the aggregated rate of all
+{{.Tab2}}streams. Examples of valid values for this option are: '500M', '10G',
+{{.Tab2}}'1.5Gbps'. The suffixes 'K', 'M', 'G' and 'T' are understood as powers
+{{.Tab2}}of 1000. The report shows how closely the achieved rate tracked the
+{{.Tab2}}target rate over periods of the duration specified by '-interval', or 1s.
+{{.Tab2}}Default: no rate limit, or '{{.DefaultUDPRate}}' with UDP
+
+{{.Tab1}}-rate-per-stream
+{{.Tab2}}apply the rate specified by '-rate' to each stream individually instead
+{{.Tab2}}of to all of them.
+
+{{.Tab1}}-reverse
+{{.Tab2}}ask the receiver to send data back over each connection for the
+{{.Tab2}}specified duration, instead of sending data to it. This is useful
+{{.Tab2}}for measuring the download direction when only this side can
+{{.Tab2}}establish connections, for instance from behind a NAT or a firewall.
+
+` + socketOptionsUsage + `
+` + tlsOptionsUsage + `
+{{.Tab1}}-servername <name>
+{{.Tab2}}name the certificate of the receiver must be valid for. It is also
+{{.Tab2}}sent to the receiver in the TLS handshake (SNI). It requires '-ca'.
+{{.Tab2}}Default: the host of the address of the receiver
+
 {{.Tab1}}-help
 {{.Tab2}}print this help
 `
@@ -288,6 +1186,11 @@ OPTIONS:
 	tmplFields["ReceiveSubCmd"] = receiveSubCmd
 	tmplFields["DefaultDuration"] = defaultDuration.String()
 	tmplFields["DefaultBufferSize"] = defaultBufferSize
+	tmplFields["DefaultDatagramSize"] = defaultDatagramSize
+	tmplFields["DatagramHeaderSize"] = fmt.Sprintf("%d", datagramHeaderSize)
+	tmplFields["MaxDatagramSize"] = fmt.Sprintf("%d", maxDatagramSize)
+	tmplFields["DefaultUDPRate"] = defaultUDPRate
 	tmplFields["DefaultParallel"] = fmt.Sprintf("%d", defaultParallel)
+	tmplFields["MaxStreams"] = fmt.Sprintf("%d", maxStreams)
 	render(template, tmplFields, f)
 }
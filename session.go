@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"sync"
+	"time"
+)
+
+// session holds the state kept by the receiver for a test requested by
+// a sender over a control connection
+type session struct {
+	id       string
+	test     testRequest
+	mu       sync.Mutex
+	reporter *intervalReporter // nil if no periodic reports are requested
+	results  []streamResult
+	complete chan struct{} // closed when all the streams reported
+
+	// streams of UDP tests, by stream identifier
+	datagrams map[int]*datagramStream
+
+	// number of connections of handshake tests
+	handshakes int
+
+	// data written by the streams of the test, shared by all of them as
+	// its content does not matter
+	buffer []byte
+}
+
+var (
+	sessionsMu sync.Mutex
+	sessions   = make(map[string]*session)
+)
+
+// newSession creates and registers a session for the given test. The
+// measurements of its streams are periodically reported by reporter, if
+// not nil.
+func newSession(test testRequest, reporter *intervalReporter) (*session, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	s := &session{
+		id:       hex.EncodeToString(id[:]),
+		test:     test,
+		reporter: reporter,
+		results:  make([]streamResult, 0, test.Streams),
+		complete: make(chan struct{}),
+	}
+	sessionsMu.Lock()
+	sessions[s.id] = s
+	sessionsMu.Unlock()
+	return s, nil
+}
+
+// lookupSession returns the registered session with the given identifier
+// or nil if there is no such session
+func lookupSession(id string) *session {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	return sessions[id]
+}
+
+// unregister removes the session from the registry
+func (s *session) unregister() {
+	sessionsMu.Lock()
+	delete(sessions, s.id)
+	sessionsMu.Unlock()
+}
+
+// addStream returns the counters to be used by the given stream of the
+// session for its data transfers over conn
+func (s *session) addStream(stream int, conn net.Conn) *streamCounters {
+	c := &streamCounters{id: stream, conn: conn}
+	if s.reporter != nil {
+		s.reporter.add(c)
+	}
+	return c
+}
+
+// addDatagram accounts for a datagram of n bytes with header h, received
+// from remote at the given time by the socket bound to local. Datagrams of
+// unknown streams are ignored.
+func (s *session) addDatagram(h datagramHeader, n int, local, remote net.Addr, arrival time.Time) {
+	stream := int(h.stream)
+	if stream >= s.test.Streams {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.datagrams == nil {
+		s.datagrams = make(map[int]*datagramStream)
+	}
+	d := s.datagrams[stream]
+	if d == nil {
+		d = &datagramStream{
+			counters: s.addStream(stream, nil),
+			local:    local,
+			remote:   remote,
+			stats:    startTransfer(s.test.Omit),
+		}
+		s.datagrams[stream] = d
+	}
+	d.add(h, n, arrival)
+}
+
+// datagramResults returns the measurements made on every stream of a UDP
+// test. It must be called once the sender is done.
+func (s *session) datagramResults() []streamResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := make([]streamResult, 0, s.test.Streams)
+	for stream := 0; stream < s.test.Streams; stream++ {
+		d := s.datagrams[stream]
+		if d == nil {
+			results = append(results, streamResult{Stream: stream, Error: "no datagrams received"})
+			continue
+		}
+		results = append(results, d.result(stream))
+	}
+	return results
+}
+
+// sendBuffer returns the buffer of the data the receiver sends over the
+// streams of the session, allocated by the first stream which sends data
+func (s *session) sendBuffer() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buffer == nil {
+		s.buffer = make([]byte, s.test.BufferSize)
+	}
+	return s.buffer
+}
+
+// addHandshake accounts for a connection of a handshake test
+func (s *session) addHandshake() {
+	s.mu.Lock()
+	s.handshakes++
+	s.mu.Unlock()
+}
+
+// handshakeCount returns the number of connections of a handshake test
+func (s *session) handshakeCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.handshakes
+}
+
+// addResult records the measurements made on one of the streams of the
+// session. Results in excess of the number of streams requested are ignored.
+func (s *session) addResult(r streamResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.results) == s.test.Streams {
+		return
+	}
+	s.results = append(s.results, r)
+	if len(s.results) == s.test.Streams {
+		close(s.complete)
+	}
+}
+
+// wait waits until all the streams of the session reported their results
+// or until timeout expires, whichever happens first, and returns the results
+// collected so far
+func (s *session) wait(timeout time.Duration) []streamResult {
+	select {
+	case <-s.complete:
+	case <-time.After(timeout):
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]streamResult(nil), s.results...)
+}
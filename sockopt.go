@@ -0,0 +1,378 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// socketConfig holds the command line options related to sockets which are
+// common to the sender and the receiver
+type socketConfig struct {
+	sndBuf       string
+	rcvBuf       string
+	congestion   string
+	nagle        bool
+	mss          int
+	tos          string
+	dscp         string
+	notSentLowat string
+	iface        string
+	ipv4         bool
+	ipv6         bool
+}
+
+// registerFlags defines in fset the command line options of c
+func (c *socketConfig) registerFlags(fset *flag.FlagSet) {
+	fset.StringVar(&c.sndBuf, "sndbuf", "", "")
+	fset.StringVar(&c.rcvBuf, "rcvbuf", "", "")
+	fset.StringVar(&c.congestion, "congestion", "", "")
+	fset.BoolVar(&c.nagle, "nagle", false, "")
+	fset.IntVar(&c.mss, "mss", 0, "")
+	fset.StringVar(&c.tos, "tos", "", "")
+	fset.StringVar(&c.dscp, "dscp", "", "")
+	fset.StringVar(&c.notSentLowat, "notsent-lowat", "", "")
+	fset.StringVar(&c.iface, "iface", "", "")
+	fset.BoolVar(&c.ipv4, "4", false, "")
+	fset.BoolVar(&c.ipv6, "6", false, "")
+}
+
+// isSet reports whether any socket option is specified
+func (c *socketConfig) isSet() bool {
+	return c.sndBuf != "" || c.rcvBuf != "" || c.congestion != "" || c.nagle ||
+		c.mss != 0 || c.tos != "" || c.dscp != "" || c.notSentLowat != "" || c.iface != ""
+}
+
+// options validates the command line options and returns the socket
+// options they specify
+func (c *socketConfig) options() (*socketOptions, error) {
+	opts := &socketOptions{}
+	switch {
+	case c.ipv4 && c.ipv6:
+		return nil, fmt.Errorf("options -4 and -6 are mutually exclusive")
+	case c.ipv4:
+		opts.family = "4"
+	case c.ipv6:
+		opts.family = "6"
+	}
+	for _, o := range []struct {
+		name  string
+		value string
+		dest  *int
+	}{
+		{"sndbuf", c.sndBuf, &opts.sndBuf},
+		{"rcvbuf", c.rcvBuf, &opts.rcvBuf},
+		{"notsent-lowat", c.notSentLowat, &opts.notSentLowat},
+	} {
+		size, err := parseBufferLength(o.value)
+		if err != nil || size < 0 || size > int64(maxSocketBuffer) {
+			return nil, fmt.Errorf("invalid %s value %q", o.name, o.value)
+		}
+		*o.dest = int(size)
+	}
+	if c.congestion != "" {
+		available, err := availableCongestionControls()
+		if err != nil {
+			return nil, fmt.Errorf("cannot determine available congestion control algorithms: %s", err)
+		}
+		if !containsString(available, c.congestion) {
+			return nil, fmt.Errorf("congestion control algorithm %q not available (available: %s)", c.congestion, strings.Join(available, ", "))
+		}
+		opts.congestion = c.congestion
+	}
+	opts.nagle = c.nagle
+	if c.mss < 0 || c.mss > maxMSS {
+		return nil, fmt.Errorf("invalid mss value %d", c.mss)
+	}
+	opts.mss = c.mss
+	switch {
+	case c.tos != "" && c.dscp != "":
+		return nil, fmt.Errorf("options -tos and -dscp are mutually exclusive")
+	case c.tos != "":
+		tos, err := strconv.ParseUint(c.tos, 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tos value %q", c.tos)
+		}
+		opts.tos, opts.tosSet = int(tos), true
+	case c.dscp != "":
+		dscp, err := strconv.ParseUint(c.dscp, 0, 6)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dscp value %q", c.dscp)
+		}
+		// The DSCP occupies the 6 most significant bits of the TOS field
+		opts.tos, opts.tosSet = int(dscp)<<2, true
+	}
+	if c.iface != "" {
+		if _, err := net.InterfaceByName(c.iface); err != nil {
+			return nil, fmt.Errorf("invalid iface value %q: %s", c.iface, err)
+		}
+		opts.iface = c.iface
+	}
+	return opts, nil
+}
+
+// maximum size of the socket buffers which can be requested
+const maxSocketBuffer = 1 << 30
+
+// maximum TCP maximum segment size which can be requested
+const maxMSS = 65535
+
+// usage template of the socket options, common to the sender and the receiver
+const socketOptionsUsage = `{{.Tab1}}-4
+{{.Tab2}}only use IPv4: host names are resolved to IPv4 addresses only.
+{{.Tab2}}This option cannot be used in combination with '-6'.
+
+{{.Tab1}}-6
+{{.Tab2}}only use IPv6: host names are resolved to IPv6 addresses only.
+{{.Tab2}}This option cannot be used in combination with '-4'.
+{{.Tab2}}By default both IPv4 and IPv6 are used.
+
+{{.Tab1}}-sndbuf <size>
+{{.Tab2}}size of the kernel send buffer (SO_SNDBUF) of each connection. The
+{{.Tab2}}kernel may adjust the requested value: the effective size is reported.
+{{.Tab2}}Examples of valid values are '256K', '4MB'.
+{{.Tab2}}Default: kernel default
+
+{{.Tab1}}-rcvbuf <size>
+{{.Tab2}}size of the kernel receive buffer (SO_RCVBUF) of each connection. The
+{{.Tab2}}kernel may adjust the requested value: the effective size is reported.
+{{.Tab2}}Default: kernel default
+
+{{.Tab1}}-congestion <name>
+{{.Tab2}}TCP congestion control algorithm (TCP_CONGESTION) of each connection,
+{{.Tab2}}such as 'cubic' or 'bbr'. The algorithm must be available in the
+{{.Tab2}}kernel. The algorithm actually in use is reported. Only supported
+{{.Tab2}}on Linux.
+{{.Tab2}}Default: kernel default
+
+{{.Tab1}}-nagle
+{{.Tab2}}enable Nagle's algorithm, i.e. clear TCP_NODELAY, on each connection.
+{{.Tab2}}By default Nagle's algorithm is disabled.
+
+{{.Tab1}}-mss <bytes>
+{{.Tab2}}maximum segment size (TCP_MAXSEG) of each connection. The kernel may
+{{.Tab2}}adjust the requested value: the effective size is reported.
+{{.Tab2}}Default: negotiated with the peer
+
+{{.Tab1}}-tos <value>
+{{.Tab2}}value of the IPv4 type of service or IPv6 traffic class field of the
+{{.Tab2}}packets of each connection, between 0 and 255. Hexadecimal values are
+{{.Tab2}}accepted with the prefix '0x', e.g. '0x10'.
+{{.Tab2}}Default: 0
+
+{{.Tab1}}-dscp <value>
+{{.Tab2}}differentiated services code point of the packets of each connection,
+{{.Tab2}}between 0 and 63, e.g. '46' for expedited forwarding. This is an
+{{.Tab2}}alternative to -tos which leaves the ECN bits clear.
+{{.Tab2}}Default: 0
+
+{{.Tab1}}-notsent-lowat <size>
+{{.Tab2}}limit of unsent data queued in the socket send buffer of each
+{{.Tab2}}connection (TCP_NOTSENT_LOWAT). Only supported on Linux.
+{{.Tab2}}Default: kernel default
+
+{{.Tab1}}-iface <name>
+{{.Tab2}}name of the network interface, such as 'eth0', every connection is
+{{.Tab2}}bound to (SO_BINDTODEVICE), regardless of the routing table. The
+{{.Tab2}}interface actually used by each connection is reported. Only
+{{.Tab2}}supported on Linux and usually requires privileges.
+{{.Tab2}}Default: chosen by the kernel
+`
+
+// socketOptions holds the options to apply to the sockets of every
+// connection established by the sender or accepted by the receiver. Zero
+// values mean the kernel defaults are used.
+type socketOptions struct {
+	family       string // "4" or "6" to restrict to IPv4 or IPv6, "" for both
+	sndBuf       int
+	rcvBuf       int
+	congestion   string
+	nagle        bool
+	mss          int
+	tos          int
+	tosSet       bool // whether tos is requested, as it can be zero
+	notSentLowat int
+	iface        string
+}
+
+// isSet reports whether any option is requested
+func (o *socketOptions) isSet() bool {
+	return o.sndBuf > 0 || o.rcvBuf > 0 || o.congestion != "" || o.mss > 0 ||
+		o.tosSet || o.notSentLowat > 0 || o.iface != ""
+}
+
+// control applies the socket options to the socket of c. It is intended to
+// be used as the Control function of net.Dialer and net.ListenConfig, so
+// that the options are set before connecting or listening. Sockets accepted
+// by a listener inherit the options of the listening socket.
+func (o *socketOptions) control(network, address string, c syscall.RawConn) error {
+	if !o.isSet() {
+		return nil
+	}
+	opts := o
+	switch {
+	case strings.HasPrefix(network, "udp"):
+		// TCP options don't apply to UDP sockets
+		opts = &socketOptions{sndBuf: o.sndBuf, rcvBuf: o.rcvBuf, tos: o.tos, tosSet: o.tosSet, iface: o.iface}
+	case network == "unix":
+		// Nor IP options to Unix domain sockets
+		opts = &socketOptions{sndBuf: o.sndBuf, rcvBuf: o.rcvBuf}
+	}
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = setSocketOptions(fd, opts)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// network returns the name of the network of the given protocol, such as
+// "tcp", restricted to the IP version requested, if any
+func (o *socketOptions) network(proto string) string {
+	return proto + o.family
+}
+
+// apply applies to conn the socket options which cannot be set before
+// the connection is established: the Go runtime disables Nagle's algorithm
+// on every TCP connection once it is established or accepted, and accepted
+// Unix domain sockets don't inherit the buffer sizes of the listening one.
+func (o *socketOptions) apply(conn net.Conn) error {
+	switch c := baseConn(conn).(type) {
+	case *net.TCPConn:
+		if o.nagle {
+			return c.SetNoDelay(false)
+		}
+	case *net.UnixConn:
+		if o.sndBuf > 0 {
+			if err := c.SetWriteBuffer(o.sndBuf); err != nil {
+				return err
+			}
+		}
+		if o.rcvBuf > 0 {
+			return c.SetReadBuffer(o.rcvBuf)
+		}
+	}
+	return nil
+}
+
+// socketInfo describes the effective configuration of the socket of a
+// connection
+type socketInfo struct {
+	SndBuf       int    `json:"sndBuf,omitempty"`
+	RcvBuf       int    `json:"rcvBuf,omitempty"`
+	Congestion   string `json:"congestion,omitempty"`
+	NoDelay      *bool  `json:"noDelay,omitempty"` // nil if not a TCP socket
+	MSS          int    `json:"mss,omitempty"`
+	TOS          *int   `json:"tos,omitempty"` // nil if not an IP socket
+	NotSentLowat int    `json:"notSentLowat,omitempty"`
+	Interface    string `json:"interface,omitempty"`
+}
+
+func (s *socketInfo) String() string {
+	var parts []string
+	if s.SndBuf > 0 {
+		parts = append(parts, fmt.Sprintf("sndbuf %s", formatSize(s.SndBuf)))
+	}
+	if s.RcvBuf > 0 {
+		parts = append(parts, fmt.Sprintf("rcvbuf %s", formatSize(s.RcvBuf)))
+	}
+	if s.Congestion != "" {
+		parts = append(parts, fmt.Sprintf("congestion %s", s.Congestion))
+	}
+	if s.NoDelay != nil && *s.NoDelay {
+		parts = append(parts, "nodelay")
+	} else if s.NoDelay != nil {
+		parts = append(parts, "nagle")
+	}
+	if s.MSS > 0 {
+		parts = append(parts, fmt.Sprintf("mss %d", s.MSS))
+	}
+	if s.TOS != nil {
+		parts = append(parts, fmt.Sprintf("tos 0x%02x (dscp %d)", *s.TOS, *s.TOS>>2))
+	}
+	if s.NotSentLowat > 0 {
+		parts = append(parts, fmt.Sprintf("notsent-lowat %s", formatSize(s.NotSentLowat)))
+	}
+	if s.Interface != "" {
+		parts = append(parts, fmt.Sprintf("iface %s", s.Interface))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// getSocketInfo retrieves from the kernel the effective configuration of
+// the socket of conn
+func getSocketInfo(conn net.Conn) (*socketInfo, error) {
+	raw, err := syscallConn(conn)
+	if err != nil {
+		return nil, err
+	}
+	info := &socketInfo{}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = readSocketInfo(fd, info)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sockErr != nil {
+		return nil, sockErr
+	}
+	if info.Interface == "" {
+		// Not bound to a device: the interface is the one owning the
+		// local address
+		switch addr := conn.LocalAddr().(type) {
+		case *net.TCPAddr:
+			info.Interface = interfaceByAddr(addr.IP)
+		case *net.UDPAddr:
+			info.Interface = interfaceByAddr(addr.IP)
+		}
+	}
+	return info, nil
+}
+
+// interfaceByAddr returns the name of the network interface which has
+// the address ip or an empty string if none has it. Addresses which are not
+// assigned to any interface, such as 127.0.0.2, are looked up in the
+// networks of the interfaces.
+func interfaceByAddr(ip net.IP) string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	var network string
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if ipnet.IP.Equal(ip) {
+				return iface.Name
+			}
+			if network == "" && ipnet.Contains(ip) {
+				network = iface.Name
+			}
+		}
+	}
+	return network
+}
+
+// formatSize formats a size in bytes using the most appropriate unit
+func formatSize(n int) string {
+	switch {
+	case n >= int(MB):
+		return fmt.Sprintf("%.2f MiB", float64(n)/float64(MB))
+	case n >= int(KB):
+		return fmt.Sprintf("%.2f KiB", float64(n)/float64(KB))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+// setSocketOptions is only implemented on Unix systems
+func setSocketOptions(fd uintptr, o *socketOptions) error {
+	return errNotSupported
+}
+
+// readSocketInfo is only implemented on Unix systems
+func readSocketInfo(fd uintptr, info *socketInfo) error {
+	return errNotSupported
+}
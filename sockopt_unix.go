@@ -0,0 +1,112 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// setSocketOptions applies the options o to the socket fd
+func setSocketOptions(fd uintptr, o *socketOptions) error {
+	if o.sndBuf > 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_SNDBUF, o.sndBuf); err != nil {
+			return err
+		}
+	}
+	if o.rcvBuf > 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF, o.rcvBuf); err != nil {
+			return err
+		}
+	}
+	if o.congestion != "" {
+		if err := setCongestion(fd, o.congestion); err != nil {
+			return fmt.Errorf("cannot set congestion control algorithm %q: %s", o.congestion, err)
+		}
+	}
+	if o.mss > 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_MAXSEG, o.mss); err != nil {
+			return fmt.Errorf("cannot set mss %d: %s", o.mss, err)
+		}
+	}
+	if o.tosSet {
+		if err := setTOS(fd, o.tos); err != nil {
+			return fmt.Errorf("cannot set tos 0x%02x: %s", o.tos, err)
+		}
+	}
+	if o.notSentLowat > 0 {
+		if err := setNotSentLowat(fd, o.notSentLowat); err != nil {
+			return fmt.Errorf("cannot set notsent-lowat %d: %s", o.notSentLowat, err)
+		}
+	}
+	if o.iface != "" {
+		if err := bindToDevice(fd, o.iface); err != nil {
+			return fmt.Errorf("cannot bind to interface %q: %s", o.iface, err)
+		}
+	}
+	return nil
+}
+
+// isIPv6 reports whether fd is an IPv6 socket
+func isIPv6(fd uintptr) bool {
+	sa, err := unix.Getsockname(int(fd))
+	if err != nil {
+		return false
+	}
+	_, ok := sa.(*unix.SockaddrInet6)
+	return ok
+}
+
+// setTOS sets the type of service of the packets sent over socket fd
+func setTOS(fd uintptr, tos int) error {
+	if !isIPv6(fd) {
+		return unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, tos)
+	}
+	// IPv6 sockets may also carry IPv4 traffic, to IPv4-mapped addresses.
+	// Not all systems allow setting IP_TOS on them.
+	unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, tos)
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, tos)
+}
+
+// getTOS returns the type of service of the packets sent over socket fd
+func getTOS(fd uintptr) (int, error) {
+	if isIPv6(fd) {
+		return unix.GetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS)
+	}
+	return unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS)
+}
+
+// readSocketInfo fills info with the effective configuration of socket fd
+func readSocketInfo(fd uintptr, info *socketInfo) error {
+	var err error
+	if info.SndBuf, err = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_SNDBUF); err != nil {
+		return err
+	}
+	if info.RcvBuf, err = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF); err != nil {
+		return err
+	}
+	if name, err := getCongestion(fd); err == nil {
+		info.Congestion = name
+	}
+	// TCP options are not available on UDP sockets
+	if noDelay, err := unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_NODELAY); err == nil {
+		enabled := noDelay != 0
+		info.NoDelay = &enabled
+	}
+	if mss, err := unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_MAXSEG); err == nil {
+		info.MSS = mss
+	}
+	// IP options are not available on Unix domain sockets
+	if tos, err := getTOS(fd); err == nil {
+		info.TOS = &tos
+	}
+	if lowat, err := getNotSentLowat(fd); err == nil && lowat > 0 {
+		// A negative value means no limit
+		info.NotSentLowat = lowat
+	}
+	if name, err := boundDevice(fd); err == nil {
+		info.Interface = name
+	}
+	return nil
+}
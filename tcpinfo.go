@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// errNotSupported is returned by the functions which are not implemented
+// on the current platform or for the current type of connection
+var errNotSupported = errors.New("not supported")
+
+// tcpInfo holds the state of a TCP connection as reported by the kernel
+type tcpInfo struct {
+	RTT         float64 `json:"rttMs"`
+	RTTVar      float64 `json:"rttVarMs"`
+	MinRTT      float64 `json:"minRttMs"`
+	Retransmits uint32  `json:"retransmits"`
+	SndCwnd     uint32  `json:"sndCwnd"` // segments
+	SndMSS      uint32  `json:"sndMss"`  // bytes
+	PacingRate  float64 `json:"pacingRateBps"`
+}
+
+func (t *tcpInfo) String() string {
+	return fmt.Sprintf("rtt %.3f ms, rttvar %.3f ms, retransmits %d, cwnd %d, pacing %s",
+		t.RTT, t.RTTVar, t.Retransmits, t.SndCwnd, formatRate(t.PacingRate))
+}
+
+// netConner is implemented by the connections layered on top of another
+// one, such as *tls.Conn
+type netConner interface {
+	NetConn() net.Conn
+}
+
+// baseConn returns the connection conn is layered on top of, if any, or
+// conn itself
+func baseConn(conn net.Conn) net.Conn {
+	for {
+		nc, ok := conn.(netConner)
+		if !ok {
+			return conn
+		}
+		conn = nc.NetConn()
+	}
+}
+
+// syscallConn returns the raw connection underlying conn
+func syscallConn(conn net.Conn) (syscall.RawConn, error) {
+	sc, ok := baseConn(conn).(syscall.Conn)
+	if !ok {
+		return nil, errNotSupported
+	}
+	return sc.SyscallConn()
+}
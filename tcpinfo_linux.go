@@ -0,0 +1,46 @@
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// getTCPInfo retrieves from the kernel the TCP_INFO statistics of conn
+func getTCPInfo(conn net.Conn) (*tcpInfo, error) {
+	raw, err := syscallConn(conn)
+	if err != nil {
+		return nil, err
+	}
+	var info *unix.TCPInfo
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		info, sockErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sockErr != nil {
+		return nil, sockErr
+	}
+	usec := func(v uint32) float64 {
+		return float64(time.Duration(v)*time.Microsecond) / float64(time.Millisecond)
+	}
+	pacingRate := float64(info.Pacing_rate) * 8
+	if info.Pacing_rate == ^uint64(0) {
+		// No pacing
+		pacingRate = 0
+	}
+	return &tcpInfo{
+		RTT:         usec(info.Rtt),
+		RTTVar:      usec(info.Rttvar),
+		MinRTT:      usec(info.Min_rtt),
+		Retransmits: info.Total_retrans,
+		SndCwnd:     info.Snd_cwnd,
+		SndMSS:      info.Snd_mss,
+		PacingRate:  pacingRate,
+	}, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"net"
+)
+
+// getTCPInfo is only implemented on Linux
+func getTCPInfo(conn net.Conn) (*tcpInfo, error) {
+	return nil, errNotSupported
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// loadCaCerts returns a pool with the PEM-formatted certificates of the file
+// at path, or nil if path is empty
+func loadCaCerts(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	caCerts, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCerts) {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return pool, nil
+}
+
+// clientTLSConfig returns the TLS configuration of the sender. The receiver
+// is verified against the CA certificates of the file ca, if specified, and
+// its certificate must be valid for serverName, if not empty, or for the
+// host of the address it is contacted at otherwise. If pin is specified,
+// the certificate of the receiver must have that SHA-256 fingerprint. If
+// cert and key are specified, the sender presents that certificate to the
+// receiver.
+func clientTLSConfig(ca, pin, cert, key, serverName string) (*tls.Config, error) {
+	if serverName != "" && ca == "" {
+		return nil, fmt.Errorf("option -servername requires -ca")
+	}
+	config := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: ca == "",
+	}
+	pool, err := loadCaCerts(ca)
+	if err != nil {
+		return nil, fmt.Errorf("error loading CA certificate from file %q: %s", ca, err)
+	}
+	config.RootCAs = pool
+	if pin != "" {
+		pinned, err := parseFingerprint(pin)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pin value %q: %s", pin, err)
+		}
+		// Also called when the certificate chain is not verified
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return fmt.Errorf("receiver presented no certificate")
+			}
+			if sum := sha256.Sum256(cs.PeerCertificates[0].Raw); !bytes.Equal(sum[:], pinned) {
+				return fmt.Errorf("certificate of the receiver does not match the pinned fingerprint (got %s)", fingerprint(cs.PeerCertificates[0].Raw))
+			}
+			return nil
+		}
+	}
+	switch {
+	case cert != "" && key != "":
+		clientCert, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate from files %q and %q: %s", cert, key, err)
+		}
+		config.Certificates = []tls.Certificate{clientCert}
+	case cert != "" || key != "":
+		return nil, fmt.Errorf("options -cert and -key must be used together")
+	}
+	return config, nil
+}
+
+// isTLSScheme reports whether the connections of the transport of scheme
+// are secured with TLS
+func isTLSScheme(scheme string) bool {
+	switch scheme {
+	case "tls", "https", "h2", "wss", "quic":
+		return true
+	}
+	return false
+}
+
+// tlsFlags holds the command line options constraining the TLS handshakes,
+// which are common to the sender and the receiver
+type tlsFlags struct {
+	minVersion string
+	maxVersion string
+	ciphers    string
+	curves     string
+}
+
+// registerFlags defines in fset the command line options of f
+func (f *tlsFlags) registerFlags(fset *flag.FlagSet) {
+	fset.StringVar(&f.minVersion, "tls-min", "", "")
+	fset.StringVar(&f.maxVersion, "tls-max", "", "")
+	fset.StringVar(&f.ciphers, "tls-ciphers", "", "")
+	fset.StringVar(&f.curves, "tls-curves", "", "")
+}
+
+// tlsVersions are the TLS versions which can be requested, by name
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsCurves are the key exchange mechanisms which can be requested
+var tlsCurves = []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521, tls.X25519MLKEM768}
+
+// apply validates the command line options and applies them to config
+func (f *tlsFlags) apply(config *tls.Config) error {
+	for _, v := range []struct {
+		name  string
+		value string
+		dest  *uint16
+	}{
+		{"tls-min", f.minVersion, &config.MinVersion},
+		{"tls-max", f.maxVersion, &config.MaxVersion},
+	} {
+		if v.value == "" {
+			continue
+		}
+		version, ok := tlsVersions[v.value]
+		if !ok {
+			return fmt.Errorf("invalid %s value %q (valid values: 1.0, 1.1, 1.2, 1.3)", v.name, v.value)
+		}
+		*v.dest = version
+	}
+	if config.MinVersion != 0 && config.MaxVersion != 0 && config.MinVersion > config.MaxVersion {
+		return fmt.Errorf("option -tls-min is greater than -tls-max")
+	}
+	if f.ciphers != "" {
+		suites := make(map[string]*tls.CipherSuite)
+		for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			suites[s.Name] = s
+		}
+		for _, name := range strings.Split(f.ciphers, ",") {
+			s, ok := suites[strings.TrimSpace(name)]
+			if !ok {
+				return fmt.Errorf("unknown cipher suite %q", name)
+			}
+			if len(s.SupportedVersions) == 1 && s.SupportedVersions[0] == tls.VersionTLS13 {
+				return fmt.Errorf("cipher suite %q cannot be selected: TLS 1.3 cipher suites are not configurable", name)
+			}
+			config.CipherSuites = append(config.CipherSuites, s.ID)
+		}
+	}
+	if f.curves != "" {
+		for _, name := range strings.Split(f.curves, ",") {
+			curve, ok := parseCurve(strings.TrimSpace(name))
+			if !ok {
+				return fmt.Errorf("unknown key exchange group %q", name)
+			}
+			config.CurvePreferences = append(config.CurvePreferences, curve)
+		}
+	}
+	return nil
+}
+
+// parseCurve returns the key exchange mechanism with the given name, such
+// as 'X25519' or 'P256', ignoring case
+func parseCurve(name string) (tls.CurveID, bool) {
+	normalize := func(s string) string {
+		return strings.ToUpper(strings.ReplaceAll(strings.TrimPrefix(s, "Curve"), "-", ""))
+	}
+	for _, c := range tlsCurves {
+		if normalize(c.String()) == normalize(name) {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// tlsInfo describes the TLS session of a connection
+type tlsInfo struct {
+	Version     string  `json:"version"`
+	CipherSuite string  `json:"cipherSuite"`
+	Curve       string  `json:"curve,omitempty"`
+	Resumed     bool    `json:"resumed,omitempty"`
+	Handshake   float64 `json:"handshakeMs"`
+}
+
+func newTLSInfo(state tls.ConnectionState, handshake time.Duration) *tlsInfo {
+	info := &tlsInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		Resumed:     state.DidResume,
+		Handshake:   float64(handshake) / float64(time.Millisecond),
+	}
+	if state.CurveID != 0 {
+		info.Curve = state.CurveID.String()
+	}
+	return info
+}
+
+func (t *tlsInfo) String() string {
+	s := fmt.Sprintf("%s, %s", t.Version, t.CipherSuite)
+	if t.Curve != "" {
+		s += ", " + t.Curve
+	}
+	if t.Resumed {
+		s += ", resumed"
+	}
+	return s + fmt.Sprintf(", handshake %.3f ms", t.Handshake)
+}
+
+// tlsInfoer is implemented by the connections which know about the TLS
+// session they run over
+type tlsInfoer interface {
+	tlsInfo() *tlsInfo
+}
+
+// getTLSInfo returns the TLS session conn runs over, or nil if none
+func getTLSInfo(conn net.Conn) *tlsInfo {
+	for {
+		if ti, ok := conn.(tlsInfoer); ok {
+			return ti.tlsInfo()
+		}
+		nc, ok := conn.(netConner)
+		if !ok {
+			return nil
+		}
+		conn = nc.NetConn()
+	}
+}
+
+// timedTLSConn is a TLS connection whose handshake duration was measured
+type timedTLSConn struct {
+	*tls.Conn
+	handshake time.Duration
+}
+
+func (c *timedTLSConn) tlsInfo() *tlsInfo {
+	return newTLSInfo(c.ConnectionState(), c.handshake)
+}
+
+// dialTLS establishes a TLS connection with addr using d and measures the
+// duration of its handshake. The socket options are applied to the
+// connection before the handshake.
+func dialTLS(ctx context.Context, d *net.Dialer, opts *socketOptions, network, addr string, config *tls.Config) (net.Conn, error) {
+	conn, err := applyOptions(opts)(d.DialContext(ctx, network, addr))
+	if err != nil {
+		return nil, err
+	}
+	if config.ServerName == "" {
+		// As tls.Dial does
+		config = config.Clone()
+		config.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+	tc := tls.Client(conn, config)
+	start := time.Now()
+	if err := tc.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &timedTLSConn{Conn: tc, handshake: time.Since(start)}, nil
+}
+
+// usage template of the TLS options, common to the sender and the receiver
+const tlsOptionsUsage = `{{.Tab1}}-tls-min <version>
+{{.Tab2}}minimum TLS version accepted: '1.0', '1.1', '1.2' or '1.3'. QUIC
+{{.Tab2}}requires TLS 1.3.
+{{.Tab2}}Default: '1.2'
+
+{{.Tab1}}-tls-max <version>
+{{.Tab2}}maximum TLS version accepted, with the same values as '-tls-min'.
+{{.Tab2}}Default: '1.3'
+
+{{.Tab1}}-tls-ciphers <list>
+{{.Tab2}}comma-separated list of the cipher suites accepted for TLS 1.2 and
+{{.Tab2}}earlier, e.g. 'TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256'. The suites
+{{.Tab2}}of TLS 1.3 cannot be selected: use '-tls-max 1.2' for comparing suites.
+{{.Tab2}}The version, cipher suite and key exchange group negotiated and the
+{{.Tab2}}duration of the handshake of each connection are reported.
+{{.Tab2}}Default: all the secure cipher suites
+
+{{.Tab1}}-tls-curves <list>
+{{.Tab2}}comma-separated list of the key exchange groups accepted, in order
+{{.Tab2}}of preference, among 'X25519', 'P256', 'P384', 'P521' and
+{{.Tab2}}'X25519MLKEM768'.
+{{.Tab2}}Default: all of them
+`
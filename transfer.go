@@ -0,0 +1,173 @@
+package main
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// transferStats holds the measurements of a one-way data transfer over
+// a network connection
+type transferStats struct {
+	start time.Time
+	end   time.Time
+	bytes uint64
+
+	// data transferred before this time is excluded from the measurements,
+	// zero if there is no such warm-up period or it is already over
+	omitUntil time.Time
+}
+
+// startTransfer returns the stats of a transfer starting now, which exclude
+// the data transferred during the initial omit period
+func startTransfer(omit time.Duration) transferStats {
+	now := time.Now()
+	s := transferStats{start: now}
+	if omit > 0 {
+		s.omitUntil = now.Add(omit)
+	}
+	return s
+}
+
+// add accounts for n bytes transferred, unless the omit period is not over.
+// When it is over, the measurement starts from that point.
+func (s *transferStats) add(n int) {
+	if !s.omitUntil.IsZero() {
+		if time.Now().Before(s.omitUntil) {
+			return
+		}
+		s.start, s.omitUntil = s.omitUntil, time.Time{}
+	}
+	s.bytes += uint64(n)
+}
+
+// finish marks the end of the transfer
+func (s *transferStats) finish() {
+	s.end = time.Now()
+	if !s.omitUntil.IsZero() {
+		// The transfer ended during the omit period: nothing is measured
+		s.start, s.omitUntil = s.end, time.Time{}
+	}
+}
+
+// dataVolume returns the amount of data transferred in MiB
+func (s transferStats) dataVolume() float64 {
+	return float64(s.bytes) / float64(MB)
+}
+
+// throughput returns the observed throughput of the transfer in MiB/sec
+func (s transferStats) throughput() float64 {
+	elapsed := s.end.Sub(s.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return s.dataVolume() / elapsed
+}
+
+// transmission describes the data to be written to a connection
+type transmission struct {
+	buffer   []byte        // contents written repeatedly
+	duration time.Duration // for how long to write
+	volume   int64         // if not zero, number of bytes to write instead of duration
+	rate     float64       // average rate in bits/sec, zero means as fast as possible
+	omit     time.Duration // initial period, in addition to duration, excluded from measurements
+}
+
+// transmit repeatedly writes the contents of the buffer of t to w
+// during the specified duration or, if the volume of t is not zero, until
+// that number of bytes is written. If a rate is specified, writes are paced
+// so that data is sent at that average rate. The number of bytes written is
+// also added to c, if not nil.
+func transmit(w io.Writer, t transmission, c *counter) (transferStats, error) {
+	stats := startTransfer(t.omit)
+	start, written := stats.start, uint64(0)
+	deadline := start.Add(t.omit + t.duration)
+	var timeout <-chan time.Time
+	if t.volume == 0 {
+		timeout = time.After(t.omit + t.duration)
+	}
+	for t.volume == 0 || written < uint64(t.volume) {
+		select {
+		case <-timeout:
+			// Stop sending data
+			stats.finish()
+			return stats, nil
+
+		default:
+		}
+		b := t.buffer
+		if t.volume > 0 {
+			if remaining := uint64(t.volume) - written; remaining < uint64(len(b)) {
+				b = b[:remaining]
+			}
+		}
+		n, err := w.Write(b)
+		written += uint64(n)
+		stats.add(n)
+		c.add(n)
+		if err != nil {
+			stats.finish()
+			return stats, err
+		}
+		if t.rate > 0 {
+			// Wait until the data written so far is due at the requested rate
+			due := start.Add(time.Duration(float64(written*8) / t.rate * float64(time.Second)))
+			if t.volume == 0 && due.After(deadline) {
+				due = deadline
+			}
+			if d := time.Until(due); d > 0 {
+				time.Sleep(d)
+			}
+		}
+	}
+	stats.finish()
+	return stats, nil
+}
+
+// drain reads and discards data from r until the other end closes
+// its side of the connection. Data read during the initial omit period is
+// excluded from the measurements. The number of bytes read is also added
+// to c, if not nil.
+func drain(r io.Reader, buffer []byte, omit time.Duration, c *counter) (transferStats, error) {
+	stats := startTransfer(omit)
+	for {
+		n, err := r.Read(buffer)
+		stats.add(n)
+		c.add(n)
+		if err != nil {
+			stats.finish()
+			if err == io.EOF {
+				return stats, nil
+			}
+			return stats, err
+		}
+	}
+}
+
+// idleReader reads from conn, failing once no data was received for
+// timeout
+type idleReader struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (r *idleReader) Read(b []byte) (int, error) {
+	r.conn.SetReadDeadline(time.Now().Add(r.timeout))
+	return r.conn.Read(b)
+}
+
+// closeWriter is implemented by connections which can be half-closed,
+// such as *net.TCPConn and *tls.Conn
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// closeWrite shuts down the writing side of conn so that the other end
+// gets an end of stream, while this side can still read from it. If conn
+// cannot be half-closed, it is fully closed.
+func closeWrite(conn net.Conn) error {
+	if cw, ok := conn.(closeWriter); ok {
+		return cw.CloseWrite()
+	}
+	return conn.Close()
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"time"
+)
+
+// In UDP tests the control connection is a TCP connection to the same
+// address and port the receiver listens to for UDP datagrams. Instead of
+// establishing data connections, every stream of the sender sends
+// datagrams stamped with the session and stream they belong to, a sequence
+// number and the time they were sent. From them the receiver measures
+// the throughput, the datagrams out of order and the jitter of each stream.
+// The datagrams lost are determined by the sender, which knows how many
+// it sent.
+
+const (
+	// identifies the datagrams sent by netperf
+	datagramMagic = 0x6e706467 // "npdg"
+
+	// size in bytes of the header of every datagram
+	datagramHeaderSize = 40
+
+	// maximum size of a datagram, imposed by the maximum size of an IPv4
+	// packet
+	maxDatagramSize = 65507
+
+	// time the receiver waits for datagrams still in flight after the
+	// sender is done
+	datagramGracePeriod = 500 * time.Millisecond
+)
+
+// datagramHeader is the header of every datagram sent in UDP tests
+type datagramHeader struct {
+	session [16]byte
+	stream  uint32
+	seq     uint64
+	sent    time.Time
+}
+
+// encode writes the encoded header to b, which must be at least
+// datagramHeaderSize bytes long
+func (h *datagramHeader) encode(b []byte) {
+	binary.BigEndian.PutUint32(b[0:], datagramMagic)
+	copy(b[4:20], h.session[:])
+	binary.BigEndian.PutUint32(b[20:], h.stream)
+	binary.BigEndian.PutUint64(b[24:], h.seq)
+	binary.BigEndian.PutUint64(b[32:], uint64(h.sent.UnixNano()))
+}
+
+// decodeDatagramHeader decodes the header of datagram b. It reports false
+// if b is not a datagram sent by netperf.
+func decodeDatagramHeader(b []byte) (datagramHeader, bool) {
+	var h datagramHeader
+	if len(b) < datagramHeaderSize || binary.BigEndian.Uint32(b[0:]) != datagramMagic {
+		return h, false
+	}
+	copy(h.session[:], b[4:20])
+	h.stream = binary.BigEndian.Uint32(b[20:])
+	h.seq = binary.BigEndian.Uint64(b[24:])
+	h.sent = time.Unix(0, int64(binary.BigEndian.Uint64(b[32:])))
+	return h, true
+}
+
+// datagramConn is used by the sender for sending the datagrams of a stream.
+// Every write sends a single datagram of the size of the data written,
+// but at least datagramHeaderSize bytes long. The data itself is not sent.
+type datagramConn struct {
+	*net.UDPConn
+	header datagramHeader
+	buffer []byte
+	sent   uint64 // number of datagrams sent
+}
+
+func newDatagramConn(conn net.Conn, session string, stream int) (*datagramConn, error) {
+	udpConn, ok := conn.(*net.UDPConn)
+	if !ok {
+		return nil, fmt.Errorf("not a UDP connection")
+	}
+	id, err := hex.DecodeString(session)
+	if err != nil || len(id) != 16 {
+		return nil, fmt.Errorf("invalid session identifier %q", session)
+	}
+	c := &datagramConn{
+		UDPConn: udpConn,
+		header:  datagramHeader{stream: uint32(stream)},
+	}
+	copy(c.header.session[:], id)
+	return c, nil
+}
+
+func (c *datagramConn) Write(b []byte) (int, error) {
+	size := len(b)
+	if size < datagramHeaderSize {
+		size = datagramHeaderSize
+	}
+	if len(c.buffer) < size {
+		c.buffer = make([]byte, size)
+	}
+	c.header.seq = c.sent
+	c.header.sent = time.Now()
+	c.header.encode(c.buffer)
+	if _, err := c.UDPConn.Write(c.buffer[:size]); err != nil {
+		return 0, err
+	}
+	c.sent++
+	return len(b), nil
+}
+
+// datagramStats holds the measurements made by the receiver on the
+// datagrams of a stream
+type datagramStats struct {
+	Received   uint64  `json:"received"`
+	OutOfOrder uint64  `json:"outOfOrder"`
+	Jitter     float64 `json:"jitterMs"` // as defined by RFC 3550
+}
+
+// datagramStream holds the state kept by the receiver for a stream of
+// a UDP test
+type datagramStream struct {
+	counters *streamCounters
+	local    net.Addr
+	remote   net.Addr
+	stats    transferStats
+	last     time.Time     // arrival time of the last datagram
+	nextSeq  uint64        // next sequence number expected
+	transit  time.Duration // relative transit time of the last datagram
+	jitter   float64       // nanoseconds
+	received uint64
+	reorder  uint64
+}
+
+// add accounts for a datagram of n bytes with header h, arrived at the
+// given time
+func (d *datagramStream) add(h datagramHeader, n int, arrival time.Time) {
+	d.stats.add(n)
+	d.counters.received.add(n)
+	d.received++
+	if h.seq < d.nextSeq {
+		d.reorder++
+	} else {
+		d.nextSeq = h.seq + 1
+	}
+	// The clocks of the sender and the receiver need not be synchronized:
+	// only differences between transit times are used
+	transit := arrival.Sub(h.sent)
+	if d.received > 1 {
+		delta := transit - d.transit
+		if delta < 0 {
+			delta = -delta
+		}
+		d.jitter += (float64(delta) - d.jitter) / 16
+	}
+	d.transit = transit
+	d.last = arrival
+}
+
+// result returns the measurements made on the stream
+func (d *datagramStream) result(stream int) streamResult {
+	d.stats.finish()
+	if d.last.After(d.stats.start) {
+		// The transfer ended with the last datagram, not when the sender
+		// reported it was done
+		d.stats.end = d.last
+	}
+	return streamResult{
+		Stream:     stream,
+		LocalAddr:  d.local.String(),
+		RemoteAddr: d.remote.String(),
+		Received:   newTransferRecord(d.stats),
+		Datagrams: &datagramStats{
+			Received:   d.received,
+			OutOfOrder: d.reorder,
+			Jitter:     d.jitter / float64(time.Millisecond),
+		},
+	}
+}
+
+// receiveDatagrams reads the datagrams of UDP tests from conn and accounts
+// for them in the stream they belong to, until conn is closed
+func receiveDatagrams(conn net.PacketConn) {
+	buffer := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			errlog.Printf("%s\n", err)
+			return
+		}
+		arrival := time.Now()
+		h, ok := decodeDatagramHeader(buffer[:n])
+		if !ok {
+			continue
+		}
+		if s := lookupSession(hex.EncodeToString(h.session[:])); s != nil {
+			s.addDatagram(h, n, conn.LocalAddr(), addr, arrival)
+		}
+	}
+}
+
+// datagramSummary holds the measurements of the datagrams of a stream of
+// a UDP test, or of all of them
+type datagramSummary struct {
+	sent       uint64
+	received   uint64
+	lost       uint64
+	outOfOrder uint64
+	jitter     float64 // milliseconds
+}
+
+// newDatagramSummary combines the number of datagrams sent on a stream with
+// the measurements made by the receiver, nil if it received none
+func newDatagramSummary(sent uint64, r *datagramStats) datagramSummary {
+	d := datagramSummary{sent: sent, lost: sent}
+	if r != nil {
+		d.received, d.outOfOrder, d.jitter = r.Received, r.OutOfOrder, r.Jitter
+		if r.Received < sent {
+			d.lost = sent - r.Received
+		} else {
+			d.lost = 0
+		}
+	}
+	return d
+}
+
+// sumDatagrams aggregates the measurements of the datagrams of several
+// streams. The jitter is the average jitter of the streams.
+func sumDatagrams(streams []datagramSummary) datagramSummary {
+	var sum datagramSummary
+	for _, d := range streams {
+		sum.sent += d.sent
+		sum.received += d.received
+		sum.lost += d.lost
+		sum.outOfOrder += d.outOfOrder
+		sum.jitter += d.jitter
+	}
+	if len(streams) > 0 {
+		sum.jitter /= float64(len(streams))
+	}
+	return sum
+}
+
+// lossRatio returns the fraction of the datagrams sent which were lost
+func (d datagramSummary) lossRatio() float64 {
+	if d.sent == 0 {
+		return 0
+	}
+	return float64(d.lost) / float64(d.sent)
+}
+
+func (d datagramSummary) String() string {
+	return fmt.Sprintf("sent %d, lost %d (%.2f%%), out of order %d, jitter %.3f ms",
+		d.sent, d.lost, 100*d.lossRatio(), d.outOfOrder, d.jitter)
+}
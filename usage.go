@@ -19,6 +19,7 @@ func printUsage(f *os.File, kind usageType) {
 USAGE:
 {{.Tab1}}{{.AppName}} {{.ReceiveCmd}} [options]
 {{.Tab1}}{{.AppName}} {{.SendCmd}} [options]
+{{.Tab1}}{{.AppName}} {{.CertsCmd}} [options]
 
 {{.Tab1}}{{.AppName}} -help
 {{.Tab1}}{{.AppName}} -version
@@ -52,6 +53,13 @@ SUBCOMMANDS:
 
 {{.Tab2}}Use '{{.AppName}} {{.SendCmd}} -help' for getting detailed help on this
 {{.Tab2}}subcommand.
+
+{{.Tab1}}{{.CertsCmd}}
+{{.Tab2}}use this subcommand to generate a throwaway CA and the certificates
+{{.Tab2}}and keys of a receiver and of a sender, for testing with TLS.
+
+{{.Tab2}}Use '{{.AppName}} {{.CertsCmd}} -help' for getting detailed help on this
+{{.Tab2}}subcommand.
 {{end}}
 `
 	if kind == usageLong {
@@ -59,6 +67,7 @@ SUBCOMMANDS:
 	}
 	tmplFields["ReceiveCmd"] = receiveSubCmd
 	tmplFields["SendCmd"] = sendSubCmd
+	tmplFields["CertsCmd"] = certsSubCmd
 	render(usageTempl, tmplFields, f)
 }
 
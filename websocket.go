@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// In WebSocket tests every connection of the test, including the control
+// connection, is a WebSocket connection. The netperf protocol is run over
+// it as over a TCP connection, its data being carried by binary messages.
+
+const (
+	// path of the requests for establishing a WebSocket connection
+	webSocketPath = "/netperf/websocket"
+
+	// maximum time for sending a close message
+	webSocketCloseTimeout = 5 * time.Second
+)
+
+// isWebSocketScheme reports whether scheme is the scheme of the addresses
+// of the WebSocket transports
+func isWebSocketScheme(scheme string) bool {
+	return scheme == "ws" || scheme == "wss"
+}
+
+// webSocketConn exposes a WebSocket connection as a network connection.
+// The data written is sent as binary messages of up to msgSize bytes and
+// the data read is the concatenation of the binary messages received.
+type webSocketConn struct {
+	*websocket.Conn           // its NetConn method returns the underlying connection
+	msgSize         int       // zero means one message per write
+	reader          io.Reader // message being read, if any
+}
+
+func newWebSocketConn(ws *websocket.Conn, msgSize int) *webSocketConn {
+	// A close message only means that the peer is done sending: the
+	// data still to be sent to it is not affected
+	ws.SetCloseHandler(func(code int, text string) error {
+		return nil
+	})
+	return &webSocketConn{Conn: ws, msgSize: msgSize}
+}
+
+func (c *webSocketConn) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		n := len(b)
+		if c.msgSize > 0 && n > c.msgSize {
+			n = c.msgSize
+		}
+		if err := c.WriteMessage(websocket.BinaryMessage, b[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// Read reads data from the binary messages received. It returns io.EOF
+// once the peer sent a normal close message.
+func (c *webSocketConn) Read(b []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			typ, r, err := c.NextReader()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+			if typ != websocket.BinaryMessage {
+				continue
+			}
+			c.reader = r
+		}
+		n, err := c.reader.Read(b)
+		if err == io.EOF {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (c *webSocketConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+// CloseWrite sends a close message, after which no more data can be written
+func (c *webSocketConn) CloseWrite() error {
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	return c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(webSocketCloseTimeout))
+}
+
+// dialWebSocket establishes a WebSocket connection with the receiver at
+// addr, of the form 'ws://host:port' or 'wss://host:port', using d for
+// establishing the underlying connection. The messages sent over it
+// are of up to msgSize bytes.
+func dialWebSocket(addr string, d *net.Dialer, opts *socketOptions, config *tls.Config, msgSize int) (net.Conn, error) {
+	dialer := websocket.Dialer{
+		NetDial: func(network, address string) (net.Conn, error) {
+			return applyOptions(opts)(d.Dial(opts.network("tcp"), address))
+		},
+		NetDialTLSContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialTLS(ctx, d, opts, opts.network("tcp"), address, config)
+		},
+		HandshakeTimeout: d.Timeout,
+	}
+	ws, resp, err := dialer.Dial(fmt.Sprintf("%s%s?msg=%d", addr, webSocketPath, msgSize), nil)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("cannot establish WebSocket connection: receiver responded %q", resp.Status)
+		}
+		return nil, err
+	}
+	return newWebSocketConn(ws, msgSize), nil
+}
+
+// handleWebSocket upgrades the connection of r to a WebSocket connection
+// and handles it as any connection accepted by the receiver
+func handleWebSocket(w http.ResponseWriter, r *http.Request, config receiverConfig) {
+	// The sender specifies the size of the messages of both ends
+	msgSize, err := strconv.ParseInt(r.URL.Query().Get("msg"), 10, 64)
+	if err != nil || msgSize < 0 || msgSize > maxBufferSize {
+		http.Error(w, "invalid message size", http.StatusBadRequest)
+		return
+	}
+	upgrader := websocket.Upgrader{
+		// Senders are not browsers
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already responded to the sender
+		errlog.Printf("cannot upgrade connection from %s: %s\n", r.RemoteAddr, err)
+		return
+	}
+	handleConnection(newWebSocketConn(ws, int(msgSize)), config)
+}